@@ -2,18 +2,25 @@ package iglocparser
 
 import (
 	"encoding/json"
-	"fmt"
 	"github.com/ansel1/merry"
+	"io"
+	"io/ioutil"
 )
 
 type City struct {
 	Id   string `json:"id"`
 	Name string `json:"name"`
 	Slug string `json:"slug"`
+
+	Country *Country `json:"country,omitempty"`
 }
 
 func (self *City) String() string {
-	return fmt.Sprintf("[id=%v; name=%v; slug=%v]", self.Id, self.Name, self.Slug)
+	return getEntityString(self)
+}
+
+func (self *City) URL() string {
+	return GetIgLinkWithLeadingSlash(IgExploreLocationsPath, self.Id, self.Slug)
 }
 
 type igApiCitiesResponse struct {
@@ -39,6 +46,16 @@ func (self *IgApiCitiesCursor) Next(client *IgApiClient) ([]*City, error) {
 		return nil, merry.Wrap(err)
 	}
 
+	res, err := decodeIgApiCitiesResponse(body)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	self.setNextPage(res.NextPage)
+	return res.cities(self.country), nil
+}
+
+func decodeIgApiCitiesResponse(body []byte) (*igApiCitiesResponse, error) {
 	res := &igApiCitiesResponse{}
 	if err := json.Unmarshal(body, res); err != nil {
 		return nil, merry.Wrap(err)
@@ -48,13 +65,38 @@ func (self *IgApiCitiesCursor) Next(client *IgApiClient) ([]*City, error) {
 		return nil, merry.WithUserMessage(ErrInvalidIgApiResponseCode, string(body))
 	}
 
+	return res, nil
+}
+
+func (self *igApiCitiesResponse) cities(country *Country) []*City {
+	if self.CountryInfo != nil {
+		country = self.CountryInfo
+	}
+
 	var cities []*City
-	for _, c := range res.CityList {
+	for _, c := range self.CityList {
+		if c.Country == nil {
+			c.Country = country
+		}
+
 		cities = append(cities, c)
 	}
 
-	self.setNextPage(res.NextPage)
-	return cities, nil
+	return cities
+}
+
+func ParseCityDirectory(r io.Reader) ([]*City, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	res, err := decodeIgApiCitiesResponse(body)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return res.cities(nil), nil
 }
 
 func GetCitiesCursor(country *Country) *IgApiCitiesCursor {
@@ -0,0 +1,75 @@
+package iglocparser
+
+import (
+	"regexp"
+	"strings"
+)
+
+type PlaceContacts struct {
+	Emails   []string `json:"emails,omitempty"`
+	Phones   []string `json:"phones,omitempty"`
+	Handles  []string `json:"handles,omitempty"`
+	Hashtags []string `json:"hashtags,omitempty"`
+	Links    []string `json:"links,omitempty"`
+}
+
+func (self *PlaceContacts) IsEmpty() bool {
+	return len(self.Emails) == 0 && len(self.Phones) == 0 && len(self.Handles) == 0 && len(self.Hashtags) == 0 && len(self.Links) == 0
+}
+
+var regexContactEmail = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+var regexContactLink = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+var regexContactHandle = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])@([A-Za-z0-9._]{1,30})`)
+var regexContactHashtag = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+var regexContactPhone = regexp.MustCompile(`\+?\d[\d\s().-]{5,}\d`)
+
+const contactLinkTrailingPunct = `.,;:!?)]}»"'`
+
+func ExtractPlaceContacts(text string, countryCode string) *PlaceContacts {
+	contacts := &PlaceContacts{}
+
+	emails := regexContactEmail.FindAllString(text, -1)
+	for _, email := range emails {
+		contacts.Emails = appendUnique(contacts.Emails, strings.ToLower(email))
+	}
+
+	rest := regexContactEmail.ReplaceAllString(text, " ")
+
+	for _, link := range regexContactLink.FindAllString(rest, -1) {
+		link = strings.TrimRight(link, contactLinkTrailingPunct)
+		if normalized := NormalizeWebsite(link); normalized != "" {
+			contacts.Links = appendUnique(contacts.Links, normalized)
+		}
+	}
+
+	rest = regexContactLink.ReplaceAllString(rest, " ")
+
+	for _, m := range regexContactHandle.FindAllStringSubmatch(rest, -1) {
+		handle := strings.TrimRight(m[1], ".")
+		if handle != "" {
+			contacts.Handles = appendUnique(contacts.Handles, strings.ToLower(handle))
+		}
+	}
+
+	for _, m := range regexContactHashtag.FindAllStringSubmatch(rest, -1) {
+		contacts.Hashtags = appendUnique(contacts.Hashtags, m[1])
+	}
+
+	for _, candidate := range regexContactPhone.FindAllString(rest, -1) {
+		if phone := NormalizePhone(candidate, countryCode); phone != "" {
+			contacts.Phones = appendUnique(contacts.Phones, phone)
+		}
+	}
+
+	return contacts
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+
+	return append(list, value)
+}
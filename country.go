@@ -2,8 +2,9 @@ package iglocparser
 
 import (
 	"encoding/json"
-	"fmt"
 	"github.com/ansel1/merry"
+	"io"
+	"io/ioutil"
 )
 
 type Country struct {
@@ -13,7 +14,11 @@ type Country struct {
 }
 
 func (self *Country) String() string {
-	return fmt.Sprintf("[id=%v; name=%v; slug=%v]", self.Id, self.Name, self.Slug)
+	return getEntityString(self)
+}
+
+func (self *Country) URL() string {
+	return GetIgLinkWithLeadingSlash(IgExploreLocationsPath, self.Id, self.Slug)
 }
 
 type igApiCountriesResponse struct {
@@ -32,6 +37,16 @@ func (self *IgApiCountriesCursor) Next(client *IgApiClient) ([]*Country, error)
 		return nil, merry.Wrap(err)
 	}
 
+	res, err := decodeIgApiCountriesResponse(body)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	self.setNextPage(res.NextPage)
+	return res.CountryList, nil
+}
+
+func decodeIgApiCountriesResponse(body []byte) (*igApiCountriesResponse, error) {
 	res := &igApiCountriesResponse{}
 	if err := json.Unmarshal(body, res); err != nil {
 		return nil, merry.Wrap(err)
@@ -41,7 +56,20 @@ func (self *IgApiCountriesCursor) Next(client *IgApiClient) ([]*Country, error)
 		return nil, merry.WithUserMessage(ErrInvalidIgApiResponseCode, string(body))
 	}
 
-	self.setNextPage(res.NextPage)
+	return res, nil
+}
+
+func ParseCountryDirectory(r io.Reader) ([]*Country, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	res, err := decodeIgApiCountriesResponse(body)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
 	return res.CountryList, nil
 }
 
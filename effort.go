@@ -1,33 +1,117 @@
 package iglocparser
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"github.com/ansel1/merry"
+	"strconv"
 	"sync"
+	"time"
 )
 
 var ErrClientsExceed = errors.New("clients exceed")
+var ErrTasksClosed = errors.New("tasks closed")
+var ErrTaskDetached = errors.New("task is not attached to a parser or worker")
 
 type EffortParseFn func(client *EffortParserClient, task *EffortParserTask) bool
 
+// effortTaskOwner runs tasks: the local EffortParser or a remote EffortWorker.
+type effortTaskOwner interface {
+	addTask(data interface{}, attempts int) error
+	clientsCount() int
+	activeClientsCount(ids []string) int
+}
+
 type EffortParserClient struct {
 	*Client
-	isInvalidated bool
+	Id string
+
+	mu                sync.Mutex
+	isInvalidated     bool
+	isRemoved         bool
+	cooldownUntil     time.Time
+	isCooldownPending bool
 }
 
 func (self *EffortParserClient) Invalidate() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
 	self.isInvalidated = true
 }
 
+// Cooldown benches the client for d. A client shared by parallel tasks is benched for all of them.
+func (self *EffortParserClient) Cooldown(d time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(self.cooldownUntil) {
+		self.cooldownUntil = until
+	}
+	self.isCooldownPending = true
+}
+
+func (self *EffortParserClient) isInvalid() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.isInvalidated
+}
+
+// takeRemoval reports true once, so only one of the parallel tasks on an invalidated client removes it.
+func (self *EffortParserClient) takeRemoval() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	isRemoved := self.isRemoved
+	self.isRemoved = true
+	return !isRemoved
+}
+
+func (self *EffortParserClient) coolingFor() time.Duration {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return time.Until(self.cooldownUntil)
+}
+
+func (self *EffortParserClient) takeCooldown() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	isPending := self.isCooldownPending
+	self.isCooldownPending = false
+	return isPending
+}
+
 type EffortParser struct {
 	mu sync.Mutex
 
-	clients     chan *EffortParserClient
-	clientsLeft int
+	clients           chan *EffortParserClient
+	clientIds         map[string]*EffortParserClient
+	clientsSeq        int
+	clientsWake       chan struct{}
+	clientParallelism int
+	running           chan struct{}
+
+	tasks       effortTaskQueue
+	tasksLeft   int
+	tasksClosed bool
+	tasksWake   chan struct{}
 
-	tasks     chan *EffortParserTask
-	tasksLeft int
+	done     chan struct{}
+	doneOnce sync.Once
 
-	done chan struct{}
+	retryPriorityStep int
+
+	store       EffortTaskStore
+	deadLetters EffortDeadLetterStore
+	err         error
+
+	handler  EffortParserEventHandler
+	counters effortParserCounters
 
 	executor EffortParseFn
 }
@@ -36,15 +120,128 @@ func (self *EffortParser) isClientsExceed() bool {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 
-	return self.clientsLeft <= 0
+	return len(self.clientIds) <= 0
 }
 
-func (self *EffortParser) decreaseClients() int {
+func (self *EffortParser) removeClient(client *EffortParserClient) {
+	self.mu.Lock()
+	if self.clientIds[client.Id] == client {
+		delete(self.clientIds, client.Id)
+	}
+	self.mu.Unlock()
+
+	select {
+	case self.clientsWake <- struct{}{}:
+	default:
+	}
+}
+
+// acquireClient waits for an idle client and returns nil once every client is invalidated.
+// A client with parallelism is queued once per parallel slot, slots of benched clients are skipped.
+func (self *EffortParser) acquireClient() *EffortParserClient {
+	for {
+		if self.isClientsExceed() {
+			return nil
+		}
+
+		select {
+		case client := <-self.clients:
+			if client.isInvalid() || client.coolingFor() > 0 {
+				self.releaseClient(client)
+				continue
+			}
+
+			return client
+		case <-self.clientsWake:
+		}
+	}
+}
+
+func (self *EffortParser) clientsCount() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return len(self.clientIds)
+}
+
+func (self *EffortParser) activeClientsCount(ids []string) int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	count := 0
+	for _, id := range ids {
+		if _, ok := self.clientIds[id]; ok {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (self *EffortParser) newClient(id string, client *Client) (*EffortParserClient, error) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 
-	self.clientsLeft--
-	return self.clientsLeft
+	if id == "" {
+		for {
+			id = strconv.Itoa(self.clientsSeq)
+			self.clientsSeq++
+			if _, ok := self.clientIds[id]; !ok {
+				break
+			}
+		}
+	} else if _, ok := self.clientIds[id]; ok {
+		return nil, merry.Errorf("duplicate effort parser client id %q", id)
+	}
+
+	c := &EffortParserClient{
+		Client: client,
+		Id:     id,
+	}
+
+	self.clientIds[id] = c
+	return c, nil
+}
+
+func (self *EffortParser) AddClient(client *Client) {
+	self.AddClientWithId("", client)
+}
+
+// AddClientWithId adds a client under a caller supplied id, e.g. its proxy URL.
+// Task exclusions refer to clients by id, so stable ids keep them meaningful across restarts.
+func (self *EffortParser) AddClientWithId(id string, client *Client) error {
+	c, err := self.newClient(id, client)
+	if err != nil {
+		return err
+	}
+
+	self.emit(EffortClientAdded, nil, c, nil)
+	for i := 0; i < self.clientParallelism; i++ {
+		self.releaseClient(c)
+	}
+	return nil
+}
+
+// releaseClient returns a slot of the client to the pool, slots of invalidated clients are dropped.
+func (self *EffortParser) releaseClient(client *EffortParserClient) {
+	if client.isInvalid() {
+		return
+	}
+
+	if d := client.coolingFor(); d > 0 {
+		time.AfterFunc(d, func() {
+			self.releaseClient(client)
+		})
+		return
+	}
+
+	select {
+	case self.clients <- client:
+	default:
+		go func() {
+			self.clients <- client
+		}()
+	}
 }
 
 func (self *EffortParser) isTasksExceed() bool {
@@ -54,95 +251,500 @@ func (self *EffortParser) isTasksExceed() bool {
 	return self.tasksLeft <= 0
 }
 
-func (self *EffortParser) decreaseTasks() int {
+func (self *EffortParser) addTask(data interface{}, attempts int) error {
+	_, err := self.AddTask(data, attempts)
+	return err
+}
+
+func (self *EffortParser) AddTask(data interface{}, attempts int) (*EffortParserTask, error) {
+	task := NewEffortParserTask(data, attempts)
+	if err := self.SubmitTask(task); err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func (self *EffortParser) SubmitTask(task *EffortParserTask) error {
+	self.mu.Lock()
+	if self.tasksClosed && self.tasksLeft <= 0 {
+		self.mu.Unlock()
+		return ErrTasksClosed
+	}
+
+	if task.Id == "" {
+		task.Id = newEffortParserTaskId()
+	} else if err := validateEffortTaskId(task.Id); err != nil {
+		self.mu.Unlock()
+		return err
+	}
+
+	task.mu.Lock()
+	task.parser = self
+	task.owner = self
+	task.mu.Unlock()
+	self.tasksLeft++
+	self.mu.Unlock()
+
+	if err := self.saveTask(task); err != nil {
+		self.mu.Lock()
+		self.tasksLeft--
+		self.mu.Unlock()
+		return err
+	}
+
+	self.pushTask(task)
+	return nil
+}
+
+func (self *EffortParser) SetTaskStore(store EffortTaskStore) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 
-	self.tasksLeft--
-	return self.tasksLeft
+	self.store = store
+}
+
+func (self *EffortParser) ResumeTasks(decode func(data json.RawMessage) (interface{}, error)) error {
+	_, err := self.resumeTasks(decode)
+	return err
+}
+
+func (self *EffortParser) resumeTasks(decode func(data json.RawMessage) (interface{}, error)) (map[string]struct{}, error) {
+	self.mu.Lock()
+	store := self.store
+	self.mu.Unlock()
+
+	resumed := make(map[string]struct{})
+	if store == nil {
+		return resumed, nil
+	}
+
+	records, err := store.LoadTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		task, err := record.task(decode)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := self.SubmitTask(task); err != nil {
+			return nil, err
+		}
+
+		resumed[task.Id] = struct{}{}
+	}
+
+	return resumed, nil
+}
+
+func (self *EffortParser) saveTask(task *EffortParserTask) error {
+	self.mu.Lock()
+	store := self.store
+	self.mu.Unlock()
+
+	if store == nil {
+		return nil
+	}
+
+	record, err := task.record()
+	if err != nil {
+		return err
+	}
+
+	return store.SaveTask(record)
+}
+
+func (self *EffortParser) deleteTask(task *EffortParserTask) error {
+	self.mu.Lock()
+	store := self.store
+	self.mu.Unlock()
+
+	if store == nil {
+		return nil
+	}
+
+	return store.DeleteTask(task.Id)
+}
+
+func (self *EffortParser) fail(err error) {
+	self.mu.Lock()
+	if self.err == nil {
+		self.err = err
+	}
+	self.mu.Unlock()
+
+	self.closeDone()
+}
+
+func (self *EffortParser) Err() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.err
+}
+
+func (self *EffortParser) CloseTasks() {
+	self.mu.Lock()
+	self.tasksClosed = true
+	isDone := self.tasksLeft <= 0
+	self.mu.Unlock()
+
+	if isDone {
+		self.closeDone()
+	}
+}
+
+func (self *EffortParser) SetRetryPriorityStep(step int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.retryPriorityStep = step
+}
+
+func (self *EffortParser) retryTask(task *EffortParserTask) {
+	self.mu.Lock()
+	step := self.retryPriorityStep
+	self.mu.Unlock()
+
+	if step != 0 {
+		task.SetPriority(task.Priority() - step)
+	}
+
+	if err := self.saveTask(task); err != nil {
+		self.fail(err)
+		return
+	}
+
+	self.emit(EffortTaskRetried, task, nil, nil)
+	self.pushTask(task)
+}
+
+func (self *EffortParser) pushTask(task *EffortParserTask) {
+	self.mu.Lock()
+	self.tasks.push(task)
+	self.mu.Unlock()
+
+	select {
+	case self.tasksWake <- struct{}{}:
+	default:
+	}
+}
+
+func (self *EffortParser) fixTask(task *EffortParserTask) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.tasks.fix(task)
+}
+
+func (self *EffortParser) popTask() *EffortParserTask {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.tasks.pop()
 }
 
 func (self *EffortParser) Run() error {
+	self.mu.Lock()
+	if self.counters.startedAt.IsZero() {
+		self.counters.startedAt = time.Now()
+	}
+	self.mu.Unlock()
+
+	// a store failure stops Run at once, queued tasks stay in the store for the next run
 	for {
-		select {
-		case <-self.done:
-			return nil
-		case task := <-self.tasks:
-			if self.isClientsExceed() {
-				return ErrClientsExceed
+		if err := self.Err(); err != nil {
+			return err
+		}
+
+		task := self.popTask()
+		if task == nil {
+			select {
+			case <-self.done:
+				return self.Err()
+			case <-self.tasksWake:
 			}
+			continue
+		}
+
+		if task.IsExpired() {
+			self.finishTask(task, EffortTaskExpired, ErrTaskExpired)
+			continue
+		}
+
+		if self.running != nil {
+			self.running <- struct{}{}
+		}
+
+		client := self.acquireClient()
+		if client == nil {
+			self.releaseRunning()
+			return ErrClientsExceed
+		}
 
-			go self.executeTask(<-self.clients, task)
+		if err := self.Err(); err != nil {
+			self.releaseClient(client)
+			self.releaseRunning()
+			self.pushTask(task)
+			return err
 		}
+
+		go self.executeTask(client, task)
+	}
+}
+
+func (self *EffortParser) releaseRunning() {
+	if self.running != nil {
+		<-self.running
 	}
 }
 
 func (self *EffortParser) executeTask(client *EffortParserClient, task *EffortParserTask) {
+	defer self.releaseRunning()
+
 	if !task.IsCanUseClient(client) {
-		self.clients <- client
+		self.releaseClient(client)
+		if task.IsValid() {
+			if task.Err() == nil {
+				task.Fail(ErrTaskExhausted)
+			}
+			self.finishTask(task, EffortTaskFailed, task.Err())
+			return
+		}
+
+		self.pushTask(task)
 		return
 	}
 
+	self.emit(EffortTaskStarted, task, client, nil)
+	startedAt := time.Now()
 	isDone := self.executor(client, task)
-	if client.isInvalidated {
-		self.decreaseClients()
-	} else {
-		self.clients <- client
+	task.addAttempt(client, startedAt)
+
+	self.mu.Lock()
+	self.counters.taskStopped(client)
+	self.mu.Unlock()
+
+	if client.isInvalid() {
+		// the event goes first, so a handler can add a replacement before Run sees the pool empty
+		if client.takeRemoval() {
+			self.emit(EffortClientInvalidated, task, client, nil)
+			self.removeClient(client)
+		}
+	} else if client.takeCooldown() {
+		self.emit(EffortClientCooledDown, task, client, nil)
 	}
+	self.releaseClient(client)
 
-	if isDone {
-		self.tryToDone()
+	if !isDone && task.IsValid() {
+		if task.Err() == nil {
+			task.Fail(ErrTaskExhausted)
+		}
+		isDone = true
+	}
+
+	if !isDone {
+		self.retryTask(task)
+	} else if err := task.Err(); err != nil {
+		self.finishTask(task, EffortTaskFailed, err)
 	} else {
-		self.tasks <- task
+		self.finishTask(task, EffortTaskFinished, nil)
+	}
+}
+
+func (self *EffortParser) finishTask(task *EffortParserTask, eventType EffortParserEventType, err error) {
+	if err != nil {
+		if derr := self.putDeadLetter(task, err); derr != nil {
+			self.fail(derr)
+			return
+		}
+	}
+
+	if derr := self.deleteTask(task); derr != nil {
+		self.fail(derr)
+		return
 	}
+
+	self.emit(eventType, task, nil, err)
+	self.tryToDone()
 }
 
 func (self *EffortParser) tryToDone() {
-	if self.decreaseTasks() <= 0 {
-		close(self.done)
+	self.mu.Lock()
+	self.tasksLeft--
+	isDone := self.tasksClosed && self.tasksLeft <= 0
+	self.mu.Unlock()
+
+	if isDone {
+		self.closeDone()
 	}
 }
 
-func NewEffortParser(clients []*Client, tasks []interface{}, attempts int, fn EffortParseFn) *EffortParser {
+func (self *EffortParser) closeDone() {
+	self.doneOnce.Do(func() {
+		close(self.done)
+	})
+}
+
+func (self *EffortParser) Done() <-chan struct{} {
+	return self.done
+}
+
+type EffortParserOptions struct {
+	// ClientIds are stable ids for clients, in the same order. Defaults to client positions,
+	// which only match stored task exclusions while the clients list keeps its order.
+	ClientIds []string
+
+	// ClientParallelism is how many tasks may run on one client at once, 1 by default.
+	ClientParallelism int
+
+	// MaxRunning caps tasks running at once over all clients, unlimited by default.
+	// Idle clients still take turns, so a benched client is replaced by any other.
+	MaxRunning int
+
+	Store       EffortTaskStore
+	DeadLetters EffortDeadLetterStore
+	Decode      func(data json.RawMessage) (interface{}, error)
+
+	// TaskId gives constructor tasks stable ids, tasks already resumed from Store or repeated are not submitted again.
+	// Without it constructor tasks are skipped whenever Store had tasks left.
+	TaskId func(data interface{}) string
+}
+
+func newEffortParser(clients []*Client, fn EffortParseFn, opts *EffortParserOptions) (*EffortParser, map[string]struct{}, error) {
+	if opts == nil {
+		opts = &EffortParserOptions{}
+	}
+
+	if opts.ClientIds != nil && len(opts.ClientIds) != len(clients) {
+		return nil, nil, merry.Errorf("got %d client ids for %d clients", len(opts.ClientIds), len(clients))
+	}
+
+	parallelism := opts.ClientParallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
 	parser := &EffortParser{
-		clients:     make(chan *EffortParserClient, len(clients)),
-		clientsLeft: len(clients),
+		clients:           make(chan *EffortParserClient, len(clients)*parallelism),
+		clientIds:         make(map[string]*EffortParserClient),
+		clientsWake:       make(chan struct{}, 1),
+		clientParallelism: parallelism,
 
-		tasks:     make(chan *EffortParserTask, len(tasks)),
-		tasksLeft: len(tasks),
+		tasksWake: make(chan struct{}, 1),
 
 		done: make(chan struct{}),
 
+		store:       opts.Store,
+		deadLetters: opts.DeadLetters,
+
 		executor: fn,
 	}
 
-	for _, client := range clients {
-		parser.clients <- &EffortParserClient{Client: client}
+	if opts.MaxRunning > 0 {
+		parser.running = make(chan struct{}, opts.MaxRunning)
 	}
 
-	for _, task := range tasks {
-		parser.tasks <- &EffortParserTask{
-			Data: task,
+	for i, client := range clients {
+		var id string
+		if opts.ClientIds != nil {
+			id = opts.ClientIds[i]
+		}
 
-			attemptsLeft: attempts,
+		c, err := parser.newClient(id, client)
+		if err != nil {
+			return nil, nil, err
+		}
 
-			parser: parser,
+		for j := 0; j < parallelism; j++ {
+			parser.clients <- c
 		}
 	}
 
+	resumed, err := parser.resumeTasks(opts.Decode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parser, resumed, nil
+}
+
+func NewEffortParser(clients []*Client, tasks []interface{}, attempts int, fn EffortParseFn) *EffortParser {
+	parser, _ := NewEffortParserWithOptions(clients, tasks, attempts, fn, nil)
 	return parser
 }
 
+// NewEffortParserWithOptions resumes tasks left in opts.Store by a previous run before submitting tasks,
+// see EffortParserOptions.TaskId for how a restarted process avoids queueing the same task twice.
+func NewEffortParserWithOptions(clients []*Client, tasks []interface{}, attempts int, fn EffortParseFn, opts *EffortParserOptions) (*EffortParser, error) {
+	parser, resumed, err := newEffortParser(clients, fn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var taskId func(data interface{}) string
+	if opts != nil {
+		taskId = opts.TaskId
+	}
+
+	if taskId == nil && len(resumed) > 0 {
+		tasks = nil
+	}
+
+	for _, data := range tasks {
+		task := NewEffortParserTask(data, attempts)
+		if taskId != nil {
+			task.Id = taskId(data)
+			if _, ok := resumed[task.Id]; ok {
+				continue
+			}
+		}
+
+		if err := parser.SubmitTask(task); err != nil {
+			return nil, err
+		}
+
+		if taskId != nil {
+			resumed[task.Id] = struct{}{}
+		}
+	}
+	parser.CloseTasks()
+
+	return parser, nil
+}
+
+func NewOpenEffortParser(clients []*Client, fn EffortParseFn) *EffortParser {
+	parser, _, _ := newEffortParser(clients, fn, nil)
+	return parser
+}
+
+func NewOpenEffortParserWithOptions(clients []*Client, fn EffortParseFn, opts *EffortParserOptions) (*EffortParser, error) {
+	parser, _, err := newEffortParser(clients, fn, opts)
+	return parser, err
+}
+
 type EffortParserTask struct {
+	Id   string
 	Data interface{}
 
 	mu             sync.Mutex
 	attemptsLeft   int
-	utilizeClients map[*EffortParserClient]struct{}
+	utilizeClients map[string]struct{}
+
+	priority int
+	deadline time.Time
+	entry    *effortTaskEntry
 
 	parser *EffortParser
+	owner  effortTaskOwner
 
-	isDone bool
+	isDone     bool
+	err        error
+	attemptErr error
+	history    []*EffortTaskAttempt
 }
 
 func NewEffortParserTask(data interface{}, attempts int) *EffortParserTask {
@@ -153,32 +755,215 @@ func NewEffortParserTask(data interface{}, attempts int) *EffortParserTask {
 	}
 }
 
+func newEffortParserTaskId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// Parser is nil when the task runs on a remote EffortWorker, use AddTask to submit follow-up tasks.
+func (self *EffortParserTask) Parser() *EffortParser {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.parser
+}
+
+// AddTask submits a follow-up task to whatever runs this task: its parser or, on a remote worker, the coordinator.
+func (self *EffortParserTask) AddTask(data interface{}, attempts int) error {
+	self.mu.Lock()
+	owner := self.owner
+	self.mu.Unlock()
+
+	if owner == nil {
+		return ErrTaskDetached
+	}
+
+	return owner.addTask(data, attempts)
+}
+
+func (self *EffortParserTask) Priority() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.priority
+}
+
+func (self *EffortParserTask) SetPriority(priority int) {
+	self.mu.Lock()
+	self.priority = priority
+	parser := self.parser
+	self.mu.Unlock()
+
+	if parser != nil {
+		parser.fixTask(self)
+	}
+}
+
+func (self *EffortParserTask) Deadline() time.Time {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.deadline
+}
+
+func (self *EffortParserTask) SetDeadline(deadline time.Time) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.deadline = deadline
+}
+
+func (self *EffortParserTask) IsExpired() bool {
+	deadline := self.Deadline()
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+func (self *EffortParserTask) Fail(err error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.err = err
+}
+
+func (self *EffortParserTask) Err() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.err
+}
+
+func (self *EffortParserTask) AddError(err error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.attemptErr = err
+}
+
+func (self *EffortParserTask) addAttempt(client *EffortParserClient, startedAt time.Time) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	attempt := &EffortTaskAttempt{
+		ClientId: client.Id,
+		Time:     startedAt,
+	}
+
+	if self.attemptErr != nil {
+		attempt.Error = self.attemptErr.Error()
+	} else if self.err != nil {
+		attempt.Error = self.err.Error()
+	}
+
+	self.attemptErr = nil
+	self.history = append(self.history, attempt)
+}
+
+func (self *EffortParserTask) History() []*EffortTaskAttempt {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return append([]*EffortTaskAttempt(nil), self.history...)
+}
+
+func (self *EffortParserTask) Clients() []string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	var clients []string
+	seen := make(map[string]struct{})
+	for _, attempt := range self.history {
+		if _, ok := seen[attempt.ClientId]; ok {
+			continue
+		}
+
+		seen[attempt.ClientId] = struct{}{}
+		clients = append(clients, attempt.ClientId)
+	}
+
+	return clients
+}
+
 func (self *EffortParserTask) AttemptsLeft() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
 	return self.attemptsLeft
 }
 
 func (self *EffortParserTask) AttemptsDecrease() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
 	self.attemptsLeft--
 	return self.attemptsLeft
 }
 
+func (self *EffortParserTask) excludedClients() []string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	ids := make([]string, 0, len(self.utilizeClients))
+	for id := range self.utilizeClients {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// IsValid reports whether the task is exhausted: no attempts are left or every active client is excluded.
+// Exclusions of clients that are gone, e.g. after a restart with another clients list, are not counted.
 func (self *EffortParserTask) IsValid() bool {
-	return self.attemptsLeft <= 0 || len(self.utilizeClients) >= cap(self.parser.clients)
+	if self.AttemptsLeft() <= 0 {
+		return true
+	}
+
+	self.mu.Lock()
+	owner := self.owner
+	self.mu.Unlock()
+
+	if owner == nil {
+		return false
+	}
+
+	return owner.activeClientsCount(self.excludedClients()) >= owner.clientsCount()
+}
+
+func (self *EffortParserTask) isExcludingAll(ids []string) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := self.utilizeClients[id]; !ok {
+			return false
+		}
+	}
+
+	return true
 }
 
 func (self *EffortParserTask) DontUseClient(client *EffortParserClient) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
 	if self.utilizeClients == nil {
-		self.utilizeClients = make(map[*EffortParserClient]struct{})
+		self.utilizeClients = make(map[string]struct{})
 	}
 
-	self.utilizeClients[client] = struct{}{}
+	self.utilizeClients[client.Id] = struct{}{}
 }
 
 func (self *EffortParserTask) IsCanUseClient(client *EffortParserClient) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
 	if self.utilizeClients == nil {
 		return true
 	}
 
-	_, ok := self.utilizeClients[client]
+	_, ok := self.utilizeClients[client.Id]
 	return !ok
 }
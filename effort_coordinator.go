@@ -0,0 +1,849 @@
+package iglocparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/ansel1/merry"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrLeaseNotFound = errors.New("lease not found")
+
+var DefaultEffortLeaseTimeout = time.Minute
+var DefaultEffortWorkerPollInterval = time.Second
+var DefaultEffortWorkerMaxRetries = 5
+var DefaultEffortWorkerRetryInterval = 500 * time.Millisecond
+var DefaultEffortWorkerMaxRetryInterval = 30 * time.Second
+
+type EffortLease struct {
+	Id        string            `json:"id"`
+	WorkerId  string            `json:"worker_id"`
+	Task      *EffortTaskRecord `json:"task"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type effortLease struct {
+	*EffortLease
+	task *EffortParserTask
+}
+
+type EffortCoordinatorStats struct {
+	TasksQueued   int  `json:"tasks_queued"`
+	TasksLeased   int  `json:"tasks_leased"`
+	TasksFinished int  `json:"tasks_finished"`
+	TasksFailed   int  `json:"tasks_failed"`
+	LeasesExpired int  `json:"leases_expired"`
+	Done          bool `json:"done"`
+}
+
+type EffortCoordinator struct {
+	mu sync.Mutex
+
+	tasks       effortTaskQueue
+	tasksClosed bool
+	leases      map[string]*effortLease
+
+	leaseTimeout time.Duration
+
+	store       EffortTaskStore
+	deadLetters EffortDeadLetterStore
+
+	stats EffortCoordinatorStats
+}
+
+func NewEffortCoordinator(leaseTimeout time.Duration) *EffortCoordinator {
+	if leaseTimeout <= 0 {
+		leaseTimeout = DefaultEffortLeaseTimeout
+	}
+
+	return &EffortCoordinator{
+		leases:       make(map[string]*effortLease),
+		leaseTimeout: leaseTimeout,
+	}
+}
+
+func (self *EffortCoordinator) SetTaskStore(store EffortTaskStore) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.store = store
+}
+
+func (self *EffortCoordinator) SetDeadLetterStore(store EffortDeadLetterStore) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.deadLetters = store
+}
+
+func (self *EffortCoordinator) ResumeTasks() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.store == nil {
+		return nil
+	}
+
+	records, err := self.store.LoadTasks()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		task, err := record.task(nil)
+		if err != nil {
+			return err
+		}
+
+		self.tasks.push(task)
+	}
+
+	return nil
+}
+
+func (self *EffortCoordinator) AddTask(data interface{}, attempts int) (*EffortParserTask, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	task := NewEffortParserTask(json.RawMessage(raw), attempts)
+	task.Id = newEffortParserTaskId()
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	// like EffortParser, closed tasks still accept follow-ups until every task is done
+	if self.tasksClosed && self.isDone() {
+		return nil, ErrTasksClosed
+	}
+
+	if err := self.saveTask(task); err != nil {
+		return nil, err
+	}
+
+	self.tasks.push(task)
+	return task, nil
+}
+
+func (self *EffortCoordinator) CloseTasks() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.tasksClosed = true
+}
+
+// Lease hands out the next task. Tasks that exclude every one of clientIds, the worker's clients,
+// are left queued for other workers; exhaustion is only decided from attempts.
+func (self *EffortCoordinator) Lease(workerId string, clientIds ...string) (*EffortLease, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if err := self.reclaimExpired(); err != nil {
+		return nil, err
+	}
+
+	var skipped []*EffortParserTask
+	defer func() {
+		for _, task := range skipped {
+			self.tasks.push(task)
+		}
+	}()
+
+	for {
+		task := self.tasks.pop()
+		if task == nil {
+			return nil, nil
+		}
+
+		if task.IsExpired() {
+			if err := self.finishTask(task, ErrTaskExpired); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if len(clientIds) > 0 && task.isExcludingAll(clientIds) {
+			skipped = append(skipped, task)
+			continue
+		}
+
+		record, err := task.record()
+		if err != nil {
+			return nil, err
+		}
+
+		lease := &effortLease{
+			EffortLease: &EffortLease{
+				Id:        newEffortParserTaskId(),
+				WorkerId:  workerId,
+				Task:      record,
+				ExpiresAt: time.Now().Add(self.leaseTimeout),
+			},
+			task: task,
+		}
+
+		self.leases[lease.Id] = lease
+		return lease.EffortLease, nil
+	}
+}
+
+func (self *EffortCoordinator) Heartbeat(leaseId string) (*EffortLease, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	lease, ok := self.leases[leaseId]
+	if !ok {
+		return nil, ErrLeaseNotFound
+	}
+
+	lease.ExpiresAt = time.Now().Add(self.leaseTimeout)
+	return lease.EffortLease, nil
+}
+
+func (self *EffortCoordinator) Complete(leaseId string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	lease, ok := self.leases[leaseId]
+	if !ok {
+		return ErrLeaseNotFound
+	}
+
+	delete(self.leases, leaseId)
+	return self.finishTask(lease.task, nil)
+}
+
+func (self *EffortCoordinator) Release(leaseId string, record *EffortTaskRecord) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	lease, ok := self.leases[leaseId]
+	if !ok {
+		return ErrLeaseNotFound
+	}
+
+	delete(self.leases, leaseId)
+
+	task, err := self.updateTask(lease, record)
+	if err != nil {
+		return err
+	}
+
+	if task.AttemptsLeft() <= 0 {
+		return self.finishTask(task, ErrTaskExhausted)
+	}
+
+	if err := self.saveTask(task); err != nil {
+		return err
+	}
+
+	self.tasks.push(task)
+	return nil
+}
+
+func (self *EffortCoordinator) Fail(leaseId string, record *EffortTaskRecord, reason string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	lease, ok := self.leases[leaseId]
+	if !ok {
+		return ErrLeaseNotFound
+	}
+
+	delete(self.leases, leaseId)
+
+	task, err := self.updateTask(lease, record)
+	if err != nil {
+		return err
+	}
+
+	if reason == "" {
+		reason = ErrTaskExhausted.Error()
+	}
+
+	return self.finishTask(task, errors.New(reason))
+}
+
+func (self *EffortCoordinator) Stats() EffortCoordinatorStats {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	stats := self.stats
+	stats.TasksQueued = self.tasks.len()
+	stats.TasksLeased = len(self.leases)
+	stats.Done = self.isDone()
+	return stats
+}
+
+func (self *EffortCoordinator) IsDone() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if err := self.reclaimExpired(); err != nil {
+		return false
+	}
+
+	return self.isDone()
+}
+
+func (self *EffortCoordinator) isDone() bool {
+	return self.tasksClosed && self.tasks.len() == 0 && len(self.leases) == 0
+}
+
+func (self *EffortCoordinator) updateTask(lease *effortLease, record *EffortTaskRecord) (*EffortParserTask, error) {
+	if record == nil {
+		return lease.task, nil
+	}
+
+	task, err := record.task(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	task.Id = lease.task.Id
+	task.Data = lease.task.Data
+	return task, nil
+}
+
+func (self *EffortCoordinator) reclaimExpired() error {
+	now := time.Now()
+	for id, lease := range self.leases {
+		if now.Before(lease.ExpiresAt) {
+			continue
+		}
+
+		delete(self.leases, id)
+		self.stats.LeasesExpired++
+
+		task := lease.task
+		task.AttemptsDecrease()
+		task.history = append(task.history, &EffortTaskAttempt{
+			ClientId: lease.WorkerId,
+			Error:    "lease expired",
+			Time:     now,
+		})
+
+		if task.AttemptsLeft() <= 0 {
+			if err := self.finishTask(task, ErrTaskExhausted); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := self.saveTask(task); err != nil {
+			return err
+		}
+
+		self.tasks.push(task)
+	}
+
+	return nil
+}
+
+func (self *EffortCoordinator) finishTask(task *EffortParserTask, err error) error {
+	if err != nil {
+		self.stats.TasksFailed++
+
+		if self.deadLetters != nil {
+			letter, lerr := newEffortDeadLetter(task, err)
+			if lerr != nil {
+				return lerr
+			}
+
+			if lerr := self.deadLetters.PutDeadLetter(letter); lerr != nil {
+				return lerr
+			}
+		}
+	} else {
+		self.stats.TasksFinished++
+	}
+
+	if self.store != nil {
+		return self.store.DeleteTask(task.Id)
+	}
+
+	return nil
+}
+
+func (self *EffortCoordinator) saveTask(task *EffortParserTask) error {
+	if self.store == nil {
+		return nil
+	}
+
+	record, err := task.record()
+	if err != nil {
+		return err
+	}
+
+	return self.store.SaveTask(record)
+}
+
+type effortCoordinatorRequest struct {
+	WorkerId  string            `json:"worker_id,omitempty"`
+	ClientIds []string          `json:"client_ids,omitempty"`
+	LeaseId   string            `json:"lease_id,omitempty"`
+	Task      *EffortTaskRecord `json:"task,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Data      json.RawMessage   `json:"data,omitempty"`
+	Attempts  int               `json:"attempts,omitempty"`
+}
+
+type effortCoordinatorResponse struct {
+	Lease *EffortLease `json:"lease,omitempty"`
+	Done  bool         `json:"done,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+func (self *EffortCoordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.TrimSuffix(r.URL.Path, "/") == "/stats" {
+		writeEffortCoordinatorJson(w, http.StatusOK, self.Stats())
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeEffortCoordinatorJson(w, http.StatusMethodNotAllowed, &effortCoordinatorResponse{Error: "method not allowed"})
+		return
+	}
+
+	req := &effortCoordinatorRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeEffortCoordinatorJson(w, http.StatusBadRequest, &effortCoordinatorResponse{Error: err.Error()})
+		return
+	}
+
+	res := &effortCoordinatorResponse{}
+	var err error
+
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/tasks":
+		_, err = self.AddTask(req.Data, req.Attempts)
+	case "/lease":
+		res.Lease, err = self.Lease(req.WorkerId, req.ClientIds...)
+		if err == nil && res.Lease == nil {
+			res.Done = self.IsDone()
+		}
+	case "/heartbeat":
+		res.Lease, err = self.Heartbeat(req.LeaseId)
+	case "/complete":
+		err = self.Complete(req.LeaseId)
+	case "/release":
+		err = self.Release(req.LeaseId, req.Task)
+	case "/fail":
+		err = self.Fail(req.LeaseId, req.Task, req.Error)
+	default:
+		writeEffortCoordinatorJson(w, http.StatusNotFound, &effortCoordinatorResponse{Error: "not found"})
+		return
+	}
+
+	if err == ErrLeaseNotFound {
+		writeEffortCoordinatorJson(w, http.StatusNotFound, &effortCoordinatorResponse{Error: err.Error()})
+		return
+	} else if err == ErrTasksClosed {
+		writeEffortCoordinatorJson(w, http.StatusConflict, &effortCoordinatorResponse{Error: err.Error()})
+		return
+	} else if err != nil {
+		writeEffortCoordinatorJson(w, http.StatusInternalServerError, &effortCoordinatorResponse{Error: err.Error()})
+		return
+	}
+
+	writeEffortCoordinatorJson(w, http.StatusOK, res)
+}
+
+func writeEffortCoordinatorJson(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+type EffortWorkerOptions struct {
+	// ClientIds are stable ids for clients, in the same order, e.g. proxy URLs.
+	// Defaults to the worker id followed by the client position.
+	ClientIds []string
+
+	Decode func(data json.RawMessage) (interface{}, error)
+
+	// ErrorHandler receives errors of heartbeat, complete, release and fail calls that are left after retries.
+	// Without a handler the first such error stops the worker and is returned from Run.
+	ErrorHandler func(lease *EffortLease, err error)
+}
+
+type EffortWorker struct {
+	Id string
+
+	coordinator string
+	http        *http.Client
+
+	mu          sync.Mutex
+	clients     chan *EffortParserClient
+	clientIds   map[string]struct{}
+	clientsWake chan struct{}
+	err         error
+
+	executor     EffortParseFn
+	decode       func(data json.RawMessage) (interface{}, error)
+	errorHandler func(lease *EffortLease, err error)
+
+	PollInterval time.Duration
+
+	// MaxRetries bounds retries of coordinator calls that failed with network errors or 5xx responses.
+	MaxRetries    int
+	RetryInterval time.Duration
+}
+
+func NewEffortWorker(coordinator string, id string, clients []*Client, fn EffortParseFn) *EffortWorker {
+	worker, _ := NewEffortWorkerWithOptions(coordinator, id, clients, fn, nil)
+	return worker
+}
+
+func NewEffortWorkerWithOptions(coordinator string, id string, clients []*Client, fn EffortParseFn, opts *EffortWorkerOptions) (*EffortWorker, error) {
+	if opts == nil {
+		opts = &EffortWorkerOptions{}
+	}
+
+	if opts.ClientIds != nil && len(opts.ClientIds) != len(clients) {
+		return nil, merry.Errorf("got %d client ids for %d clients", len(opts.ClientIds), len(clients))
+	}
+
+	worker := &EffortWorker{
+		Id: id,
+
+		coordinator: strings.TrimSuffix(coordinator, "/"),
+		http:        &http.Client{Timeout: DefaultEffortLeaseTimeout},
+
+		clients:     make(chan *EffortParserClient, len(clients)),
+		clientIds:   make(map[string]struct{}),
+		clientsWake: make(chan struct{}, 1),
+
+		executor:     fn,
+		decode:       opts.Decode,
+		errorHandler: opts.ErrorHandler,
+
+		PollInterval: DefaultEffortWorkerPollInterval,
+
+		MaxRetries:    DefaultEffortWorkerMaxRetries,
+		RetryInterval: DefaultEffortWorkerRetryInterval,
+	}
+
+	for i, client := range clients {
+		clientId := id + "/" + strconv.Itoa(i)
+		if opts.ClientIds != nil {
+			clientId = opts.ClientIds[i]
+		}
+
+		if _, ok := worker.clientIds[clientId]; ok {
+			return nil, merry.Errorf("duplicate effort worker client id %q", clientId)
+		}
+
+		worker.clientIds[clientId] = struct{}{}
+		worker.clients <- &EffortParserClient{
+			Client: client,
+			Id:     clientId,
+		}
+	}
+
+	return worker, nil
+}
+
+func (self *EffortWorker) SetDecoder(decode func(data json.RawMessage) (interface{}, error)) {
+	self.decode = decode
+}
+
+func (self *EffortWorker) clientsCount() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return len(self.clientIds)
+}
+
+func (self *EffortWorker) activeClientsCount(ids []string) int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	count := 0
+	for _, id := range ids {
+		if _, ok := self.clientIds[id]; ok {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (self *EffortWorker) activeClientIds() []string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	ids := make([]string, 0, len(self.clientIds))
+	for id := range self.clientIds {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func (self *EffortWorker) removeClient(client *EffortParserClient) {
+	self.mu.Lock()
+	delete(self.clientIds, client.Id)
+	self.mu.Unlock()
+
+	select {
+	case self.clientsWake <- struct{}{}:
+	default:
+	}
+}
+
+func (self *EffortWorker) acquireClient() *EffortParserClient {
+	for {
+		if self.clientsCount() <= 0 {
+			return nil
+		}
+
+		select {
+		case client := <-self.clients:
+			return client
+		case <-self.clientsWake:
+		}
+	}
+}
+
+func (self *EffortWorker) releaseClient(client *EffortParserClient) {
+	client.takeCooldown()
+	if client.isInvalid() {
+		self.removeClient(client)
+	} else if d := client.coolingFor(); d > 0 {
+		time.AfterFunc(d, func() {
+			self.clients <- client
+		})
+	} else {
+		self.clients <- client
+	}
+}
+
+func (self *EffortWorker) Err() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.err
+}
+
+func (self *EffortWorker) report(lease *EffortLease, err error) {
+	if err == nil {
+		return
+	}
+
+	if self.errorHandler != nil {
+		self.errorHandler(lease, err)
+		return
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.err == nil {
+		self.err = err
+	}
+}
+
+func (self *EffortWorker) addTask(data interface{}, attempts int) error {
+	return self.AddTask(data, attempts)
+}
+
+// AddTask submits a task to the coordinator, executors reach it through EffortParserTask.AddTask.
+func (self *EffortWorker) AddTask(data interface{}, attempts int) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	_, err = self.call("/tasks", &effortCoordinatorRequest{Data: raw, Attempts: attempts})
+	return err
+}
+
+func (self *EffortWorker) Run() error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		if err := self.Err(); err != nil {
+			return err
+		}
+
+		client := self.acquireClient()
+		if client == nil {
+			return ErrClientsExceed
+		}
+
+		res, err := self.call("/lease", &effortCoordinatorRequest{WorkerId: self.Id, ClientIds: self.activeClientIds()})
+		if err != nil {
+			self.clients <- client
+			return err
+		}
+
+		if res.Lease == nil {
+			self.clients <- client
+			if res.Done {
+				return nil
+			}
+
+			time.Sleep(self.PollInterval)
+			continue
+		}
+
+		wg.Add(1)
+		go func(lease *EffortLease) {
+			defer wg.Done()
+			self.execute(client, lease)
+		}(res.Lease)
+	}
+}
+
+func (self *EffortWorker) execute(client *EffortParserClient, lease *EffortLease) {
+	task, err := lease.Task.task(self.decode)
+	if err != nil {
+		self.clients <- client
+		self.finish(lease, "/fail", &effortCoordinatorRequest{LeaseId: lease.Id, Error: err.Error()})
+		return
+	}
+
+	task.mu.Lock()
+	task.owner = self
+	task.mu.Unlock()
+
+	// other workers may still run a task that excludes this worker's clients, the coordinator
+	// keeps the exclusions and decides exhaustion from attempts alone
+	if task.IsValid() || !task.IsCanUseClient(client) {
+		self.clients <- client
+		self.finish(lease, "/release", &effortCoordinatorRequest{LeaseId: lease.Id, Task: lease.Task})
+		return
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		self.heartbeat(lease, stop)
+	}()
+
+	startedAt := time.Now()
+	isDone := self.executor(client, task)
+	task.addAttempt(client, startedAt)
+	close(stop)
+	<-stopped
+
+	self.releaseClient(client)
+
+	record, err := task.record()
+	if err != nil {
+		self.finish(lease, "/fail", &effortCoordinatorRequest{LeaseId: lease.Id, Error: err.Error()})
+		return
+	}
+	record.Data = lease.Task.Data
+
+	if !isDone {
+		self.finish(lease, "/release", &effortCoordinatorRequest{LeaseId: lease.Id, Task: record})
+	} else if err := task.Err(); err != nil {
+		self.finish(lease, "/fail", &effortCoordinatorRequest{LeaseId: lease.Id, Task: record, Error: err.Error()})
+	} else {
+		self.finish(lease, "/complete", &effortCoordinatorRequest{LeaseId: lease.Id})
+	}
+}
+
+func (self *EffortWorker) finish(lease *EffortLease, path string, req *effortCoordinatorRequest) {
+	_, err := self.call(path, req)
+	self.report(lease, err)
+}
+
+func (self *EffortWorker) heartbeat(lease *EffortLease, stop chan struct{}) {
+	interval := time.Until(lease.ExpiresAt) / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_, err := self.call("/heartbeat", &effortCoordinatorRequest{LeaseId: lease.Id})
+			if err == nil {
+				continue
+			}
+
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			self.report(lease, err)
+			if merry.Is(err, ErrLeaseNotFound) {
+				return
+			}
+		}
+	}
+}
+
+// call retries network errors and 5xx responses with exponential backoff.
+func (self *EffortWorker) call(path string, req *effortCoordinatorRequest) (*effortCoordinatorResponse, error) {
+	interval := self.RetryInterval
+	for i := 0; ; i++ {
+		res, isRetryable, err := self.callOnce(path, req)
+		if err == nil || !isRetryable || i >= self.MaxRetries {
+			return res, err
+		}
+
+		time.Sleep(interval)
+		if interval *= 2; interval > DefaultEffortWorkerMaxRetryInterval {
+			interval = DefaultEffortWorkerMaxRetryInterval
+		}
+	}
+}
+
+func (self *EffortWorker) callOnce(path string, req *effortCoordinatorRequest) (*effortCoordinatorResponse, bool, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, false, merry.Wrap(err)
+	}
+
+	resp, err := self.http.Post(self.coordinator+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, true, merry.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, merry.Wrap(err)
+	}
+
+	isRetryable := resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+
+	res := &effortCoordinatorResponse{}
+	if err := json.Unmarshal(data, res); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, isRetryable, merry.WithHTTPCode(ErrInvalidResponseStatus, resp.StatusCode)
+		}
+
+		return nil, false, merry.Wrap(err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound && res.Error == ErrLeaseNotFound.Error() {
+		return nil, false, merry.Wrap(ErrLeaseNotFound)
+	} else if resp.StatusCode == http.StatusConflict && res.Error == ErrTasksClosed.Error() {
+		return nil, false, merry.Wrap(ErrTasksClosed)
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, isRetryable, merry.WithUserMessage(merry.WithHTTPCode(ErrInvalidResponseStatus, resp.StatusCode), res.Error)
+	}
+
+	return res, false, nil
+}
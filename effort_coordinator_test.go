@@ -0,0 +1,380 @@
+package iglocparser
+
+import (
+	"encoding/json"
+	"github.com/ansel1/merry"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestEffortWorker(t *testing.T, url string, id string, clients int, fn EffortParseFn, opts *EffortWorkerOptions) *EffortWorker {
+	t.Helper()
+
+	if opts == nil {
+		opts = &EffortWorkerOptions{}
+	}
+
+	if opts.Decode == nil {
+		opts.Decode = decodeEffortTestString
+	}
+
+	worker, err := NewEffortWorkerWithOptions(url, id, newTestClients(clients), fn, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worker.PollInterval = 10 * time.Millisecond
+	worker.RetryInterval = time.Millisecond
+	return worker
+}
+
+func runEffortWorker(t *testing.T, worker *EffortWorker) error {
+	t.Helper()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- worker.Run()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("effort worker did not finish in time")
+	}
+
+	return nil
+}
+
+func TestEffortWorkerFollowUpTasks(t *testing.T) {
+	coordinator := NewEffortCoordinator(time.Second)
+	if _, err := coordinator.AddTask("city", 1); err != nil {
+		t.Fatal(err)
+	}
+	coordinator.CloseTasks()
+
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	log := &effortTestLog{}
+	worker := newTestEffortWorker(t, server.URL, "w", 2, func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(task.Data)
+		if task.Parser() != nil {
+			t.Error("expected no local parser on a worker")
+		}
+
+		if task.Data == "city" {
+			for _, data := range []string{"location-1", "location-2"} {
+				if err := task.AddTask(data, 1); err != nil {
+					t.Errorf("failed to add follow-up task: %v", err)
+				}
+			}
+		}
+
+		return true
+	}, nil)
+
+	if err := runEffortWorker(t, worker); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := log.get(); len(got) != 3 {
+		t.Fatalf("expected city and two follow-ups, got %v", got)
+	}
+
+	if stats := coordinator.Stats(); stats.TasksFinished != 3 || !stats.Done {
+		t.Fatalf("unexpected coordinator stats %+v", stats)
+	}
+
+	if err := worker.AddTask("late", 1); !merry.Is(err, ErrTasksClosed) {
+		t.Fatalf("expected ErrTasksClosed, got %v", err)
+	}
+}
+
+func TestEffortWorkerExcludedClients(t *testing.T) {
+	deadLetters := NewMemoryEffortDeadLetterStore()
+	coordinator := NewEffortCoordinator(time.Second)
+	coordinator.SetDeadLetterStore(deadLetters)
+	if _, err := coordinator.AddTask("place", 5); err != nil {
+		t.Fatal(err)
+	}
+	coordinator.CloseTasks()
+
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	log := &effortTestLog{}
+	first := newTestEffortWorker(t, server.URL, "w1", 2, func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(client.Id)
+		task.DontUseClient(client)
+		return false
+	}, &EffortWorkerOptions{ClientIds: []string{"http://proxy-a", "http://proxy-b"}})
+
+	firstErr := make(chan error, 1)
+	go func() {
+		firstErr <- first.Run()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(log.get()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("first worker did not try both of its clients")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// the task excludes every client of the first worker, it stays queued instead of being dead-lettered
+	time.Sleep(50 * time.Millisecond)
+	if stats := coordinator.Stats(); stats.TasksQueued != 1 || stats.TasksFailed != 0 {
+		t.Fatalf("unexpected coordinator stats %+v", stats)
+	}
+
+	second := newTestEffortWorker(t, server.URL, "w2", 1, func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(client.Id)
+		if task.AttemptsLeft() != 5 {
+			t.Errorf("expected excluded clients not to use attempts, got %d left", task.AttemptsLeft())
+		}
+		return true
+	}, &EffortWorkerOptions{ClientIds: []string{"http://proxy-c"}})
+
+	if err := runEffortWorker(t, second); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-firstErr:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("first worker did not finish in time")
+	}
+
+	got := log.get()
+	if len(got) != 3 || got[0] == got[1] || got[2] != "http://proxy-c" {
+		t.Fatalf("expected one attempt per client of the first worker, then the second worker, got %v", got)
+	}
+
+	if letters, err := deadLetters.LoadDeadLetters(); err != nil || len(letters) != 0 {
+		t.Fatalf("unexpected dead letters %+v %v", letters, err)
+	}
+
+	if stats := coordinator.Stats(); stats.TasksFinished != 1 {
+		t.Fatalf("unexpected coordinator stats %+v", stats)
+	}
+}
+
+func TestEffortWorkerExhaustedByAttempts(t *testing.T) {
+	deadLetters := NewMemoryEffortDeadLetterStore()
+	coordinator := NewEffortCoordinator(time.Second)
+	coordinator.SetDeadLetterStore(deadLetters)
+	if _, err := coordinator.AddTask("place", 2); err != nil {
+		t.Fatal(err)
+	}
+	coordinator.CloseTasks()
+
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	log := &effortTestLog{}
+	worker := newTestEffortWorker(t, server.URL, "w", 1, func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(task.AttemptsLeft())
+		task.AttemptsDecrease()
+		return false
+	}, nil)
+
+	if err := runEffortWorker(t, worker); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEffortTestLog(t, log, 2, 1)
+
+	letters, err := deadLetters.LoadDeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(letters) != 1 || letters[0].Err != ErrTaskExhausted.Error() || len(letters[0].History) != 2 {
+		t.Fatalf("unexpected dead letters %+v", letters)
+	}
+}
+
+func TestEffortWorkerSkipsStoredExclusions(t *testing.T) {
+	store, err := NewFileEffortTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SaveTask(&EffortTaskRecord{
+		Id:              "place",
+		Data:            json.RawMessage(`"place"`),
+		AttemptsLeft:    1,
+		ExcludedClients: []string{"http://proxy-a"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	coordinator := NewEffortCoordinator(time.Second)
+	coordinator.SetTaskStore(store)
+	if err := coordinator.ResumeTasks(); err != nil {
+		t.Fatal(err)
+	}
+	coordinator.CloseTasks()
+
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+
+	log := &effortTestLog{}
+	worker := newTestEffortWorker(t, server.URL, "w", 2, func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(client.Id)
+		return true
+	}, &EffortWorkerOptions{ClientIds: []string{"http://proxy-a", "http://proxy-b"}})
+
+	if err := runEffortWorker(t, worker); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEffortTestLog(t, log, "http://proxy-b")
+}
+
+func TestEffortWorkerRetriesTransientErrors(t *testing.T) {
+	coordinator := NewEffortCoordinator(time.Second)
+	if _, err := coordinator.AddTask("place", 1); err != nil {
+		t.Fatal(err)
+	}
+	coordinator.CloseTasks()
+
+	var mu sync.Mutex
+	failures := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every call fails twice before it reaches the coordinator
+		mu.Lock()
+		failures[r.URL.Path]++
+		isFailed := failures[r.URL.Path]%3 != 0
+		mu.Unlock()
+
+		if isFailed {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+
+		coordinator.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	worker := newTestEffortWorker(t, server.URL, "w", 1, func(client *EffortParserClient, task *EffortParserTask) bool {
+		return true
+	}, nil)
+	worker.MaxRetries = 2
+
+	if err := runEffortWorker(t, worker); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := coordinator.Stats(); stats.TasksFinished != 1 {
+		t.Fatalf("unexpected coordinator stats %+v", stats)
+	}
+}
+
+func newFailingCompleteServer(coordinator *EffortCoordinator, completes *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/complete") {
+			atomic.AddInt32(completes, 1)
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		coordinator.ServeHTTP(w, r)
+	}))
+}
+
+func TestEffortWorkerStopsOnFailedComplete(t *testing.T) {
+	coordinator := NewEffortCoordinator(time.Second)
+	if _, err := coordinator.AddTask("place", 1); err != nil {
+		t.Fatal(err)
+	}
+	coordinator.CloseTasks()
+
+	var completes int32
+	server := newFailingCompleteServer(coordinator, &completes)
+	defer server.Close()
+
+	worker := newTestEffortWorker(t, server.URL, "w", 1, func(client *EffortParserClient, task *EffortParserTask) bool {
+		return true
+	}, nil)
+	worker.MaxRetries = 3
+
+	if err := runEffortWorker(t, worker); !merry.Is(err, ErrInvalidResponseStatus) {
+		t.Fatalf("expected the failed complete call to stop the worker, got %v", err)
+	}
+
+	if n := atomic.LoadInt32(&completes); n != 4 {
+		t.Fatalf("expected 4 complete calls, got %d", n)
+	}
+}
+
+func TestEffortWorkerReportsFailedComplete(t *testing.T) {
+	coordinator := NewEffortCoordinator(100 * time.Millisecond)
+	if _, err := coordinator.AddTask("place", 1); err != nil {
+		t.Fatal(err)
+	}
+	coordinator.CloseTasks()
+
+	var completes int32
+	server := newFailingCompleteServer(coordinator, &completes)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var reported []error
+	worker := newTestEffortWorker(t, server.URL, "w", 1, func(client *EffortParserClient, task *EffortParserTask) bool {
+		return true
+	}, &EffortWorkerOptions{ErrorHandler: func(lease *EffortLease, err error) {
+		mu.Lock()
+		reported = append(reported, err)
+		mu.Unlock()
+	}})
+	worker.MaxRetries = 0
+
+	// the worker keeps polling until the lease expires and the task runs out of attempts
+	if err := runEffortWorker(t, worker); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(reported) != 1 || !merry.Is(reported[0], ErrInvalidResponseStatus) {
+		t.Fatalf("expected the failed complete call to be reported, got %v", reported)
+	}
+
+	if stats := coordinator.Stats(); stats.LeasesExpired != 1 || stats.TasksFailed != 1 {
+		t.Fatalf("unexpected coordinator stats %+v", stats)
+	}
+}
+
+func TestEffortWorkerLeaseError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	worker := newTestEffortWorker(t, server.URL, "w", 1, func(client *EffortParserClient, task *EffortParserTask) bool {
+		return true
+	}, nil)
+	worker.MaxRetries = 2
+
+	if err := runEffortWorker(t, worker); err == nil {
+		t.Fatal("expected lease error")
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("expected 3 lease calls, got %d", n)
+	}
+}
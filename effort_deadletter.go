@@ -0,0 +1,206 @@
+package iglocparser
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ansel1/merry"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrTaskExhausted = errors.New("task exhausted attempts or clients")
+var ErrTaskExpired = errors.New("task deadline exceeded")
+var ErrInvalidTaskId = errors.New("invalid task id")
+
+type EffortTaskAttempt struct {
+	ClientId string    `json:"client_id,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type EffortDeadLetter struct {
+	Id       string               `json:"id"`
+	Data     json.RawMessage      `json:"data"`
+	Err      string               `json:"error"`
+	History  []*EffortTaskAttempt `json:"history,omitempty"`
+	Clients  []string             `json:"clients,omitempty"`
+	FailedAt time.Time            `json:"failed_at"`
+}
+
+type EffortDeadLetterStore interface {
+	PutDeadLetter(letter *EffortDeadLetter) error
+	LoadDeadLetters() ([]*EffortDeadLetter, error)
+	DeleteDeadLetter(id string) error
+}
+
+func newEffortDeadLetter(task *EffortParserTask, err error) (*EffortDeadLetter, error) {
+	data, merr := json.Marshal(task.Data)
+	if merr != nil {
+		return nil, merry.Wrap(merr)
+	}
+
+	letter := &EffortDeadLetter{
+		Id:       task.Id,
+		Data:     data,
+		History:  task.History(),
+		Clients:  task.Clients(),
+		FailedAt: time.Now(),
+	}
+
+	if err != nil {
+		letter.Err = err.Error()
+	}
+
+	return letter, nil
+}
+
+type MemoryEffortDeadLetterStore struct {
+	mu      sync.Mutex
+	letters map[string]*EffortDeadLetter
+}
+
+func NewMemoryEffortDeadLetterStore() *MemoryEffortDeadLetterStore {
+	return &MemoryEffortDeadLetterStore{
+		letters: make(map[string]*EffortDeadLetter),
+	}
+}
+
+func (self *MemoryEffortDeadLetterStore) PutDeadLetter(letter *EffortDeadLetter) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.letters[letter.Id] = letter
+	return nil
+}
+
+func (self *MemoryEffortDeadLetterStore) LoadDeadLetters() ([]*EffortDeadLetter, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	letters := make([]*EffortDeadLetter, 0, len(self.letters))
+	for _, letter := range self.letters {
+		letters = append(letters, letter)
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+
+	return letters, nil
+}
+
+func (self *MemoryEffortDeadLetterStore) DeleteDeadLetter(id string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	delete(self.letters, id)
+	return nil
+}
+
+type FileEffortDeadLetterStore struct {
+	dir string
+}
+
+func NewFileEffortDeadLetterStore(dir string) (*FileEffortDeadLetterStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return &FileEffortDeadLetterStore{
+		dir: dir,
+	}, nil
+}
+
+func (self *FileEffortDeadLetterStore) PutDeadLetter(letter *EffortDeadLetter) error {
+	return writeJsonFileAtomic(self.dir, letter.Id, letter)
+}
+
+func (self *FileEffortDeadLetterStore) LoadDeadLetters() ([]*EffortDeadLetter, error) {
+	var letters []*EffortDeadLetter
+	err := readJsonFiles(self.dir, func(body []byte) error {
+		letter := &EffortDeadLetter{}
+		if err := json.Unmarshal(body, letter); err != nil {
+			return merry.Wrap(err)
+		}
+
+		letters = append(letters, letter)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+
+	return letters, nil
+}
+
+func (self *FileEffortDeadLetterStore) DeleteDeadLetter(id string) error {
+	path, err := effortJsonFilePath(self.dir, id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return merry.Wrap(err)
+	}
+
+	return nil
+}
+
+func (self *EffortParser) SetDeadLetterStore(store EffortDeadLetterStore) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.deadLetters = store
+}
+
+func (self *EffortParser) putDeadLetter(task *EffortParserTask, err error) error {
+	self.mu.Lock()
+	store := self.deadLetters
+	self.mu.Unlock()
+
+	if store == nil {
+		return nil
+	}
+
+	letter, lerr := newEffortDeadLetter(task, err)
+	if lerr != nil {
+		return lerr
+	}
+
+	return store.PutDeadLetter(letter)
+}
+
+func (self *EffortParser) RedriveDeadLetters(store EffortDeadLetterStore, attempts int, decode func(data json.RawMessage) (interface{}, error)) (int, error) {
+	letters, err := store.LoadDeadLetters()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, letter := range letters {
+		var data interface{} = letter.Data
+		if decode != nil {
+			if data, err = decode(letter.Data); err != nil {
+				return count, merry.Wrap(err)
+			}
+		}
+
+		if err := self.SubmitTask(NewEffortParserTask(data, attempts)); err != nil {
+			return count, err
+		}
+
+		if err := store.DeleteDeadLetter(letter.Id); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
@@ -0,0 +1,92 @@
+package iglocparser
+
+import (
+	"container/heap"
+)
+
+type effortTaskEntry struct {
+	task     *EffortParserTask
+	priority int
+	seq      uint64
+	index    int
+}
+
+type effortTaskHeap []*effortTaskEntry
+
+func (self effortTaskHeap) Len() int {
+	return len(self)
+}
+
+func (self effortTaskHeap) Less(i, j int) bool {
+	if self[i].priority != self[j].priority {
+		return self[i].priority > self[j].priority
+	}
+
+	return self[i].seq < self[j].seq
+}
+
+func (self effortTaskHeap) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+	self[i].index = i
+	self[j].index = j
+}
+
+func (self *effortTaskHeap) Push(x interface{}) {
+	entry := x.(*effortTaskEntry)
+	entry.index = len(*self)
+	*self = append(*self, entry)
+}
+
+func (self *effortTaskHeap) Pop() interface{} {
+	old := *self
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*self = old[:n-1]
+	return entry
+}
+
+// effortTaskQueue is not safe for concurrent use, the owner guards it with its own mutex.
+// Entries copy the task priority on push, so tasks may change priority while queued
+// without racing with the heap; fix re-reads it.
+type effortTaskQueue struct {
+	tasks effortTaskHeap
+	seq   uint64
+}
+
+func (self *effortTaskQueue) push(task *EffortParserTask) {
+	self.seq++
+	entry := &effortTaskEntry{
+		task:     task,
+		priority: task.Priority(),
+		seq:      self.seq,
+	}
+
+	task.entry = entry
+	heap.Push(&self.tasks, entry)
+}
+
+func (self *effortTaskQueue) pop() *EffortParserTask {
+	if len(self.tasks) == 0 {
+		return nil
+	}
+
+	entry := heap.Pop(&self.tasks).(*effortTaskEntry)
+	entry.task.entry = nil
+	return entry.task
+}
+
+func (self *effortTaskQueue) fix(task *EffortParserTask) {
+	entry := task.entry
+	if entry == nil || entry.index < 0 {
+		return
+	}
+
+	entry.priority = task.Priority()
+	heap.Fix(&self.tasks, entry.index)
+}
+
+func (self *effortTaskQueue) len() int {
+	return len(self.tasks)
+}
@@ -0,0 +1,188 @@
+package iglocparser
+
+import (
+	"time"
+)
+
+type EffortParserEventType int
+
+const (
+	EffortTaskStarted EffortParserEventType = iota
+	EffortTaskFinished
+	EffortTaskRetried
+	EffortTaskFailed
+	EffortTaskExpired
+	EffortClientAdded
+	// EffortClientInvalidated is emitted before the client leaves the pool, a handler may add
+	// a replacement with AddClient to keep Run from returning ErrClientsExceed.
+	EffortClientInvalidated
+	EffortClientCooledDown
+)
+
+func (self EffortParserEventType) String() string {
+	switch self {
+	case EffortTaskStarted:
+		return "task_started"
+	case EffortTaskFinished:
+		return "task_finished"
+	case EffortTaskRetried:
+		return "task_retried"
+	case EffortTaskFailed:
+		return "task_failed"
+	case EffortTaskExpired:
+		return "task_expired"
+	case EffortClientAdded:
+		return "client_added"
+	case EffortClientInvalidated:
+		return "client_invalidated"
+	case EffortClientCooledDown:
+		return "client_cooled_down"
+	}
+
+	return "unknown"
+}
+
+type EffortParserEvent struct {
+	Type   EffortParserEventType
+	Time   time.Time
+	Task   *EffortParserTask
+	Client *EffortParserClient
+	Err    error
+}
+
+// EffortParserEventHandler is called synchronously from worker goroutines and must be safe for concurrent use.
+type EffortParserEventHandler func(event *EffortParserEvent)
+
+type EffortParserStats struct {
+	StartedAt time.Time
+	Elapsed   time.Duration
+
+	TasksLeft     int
+	TasksQueued   int
+	TasksRunning  int
+	TasksFinished int
+	TasksFailed   int
+	TasksExpired  int
+	TasksRetried  int
+
+	ClientsActive      int
+	ClientsBusy        int
+	ClientsCooling     int
+	ClientsInvalidated int
+}
+
+func (self *EffortParserStats) Throughput() float64 {
+	if self.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(self.TasksFinished+self.TasksFailed) / self.Elapsed.Seconds()
+}
+
+type effortParserCounters struct {
+	startedAt time.Time
+
+	tasksRunning  int
+	tasksFinished int
+	tasksFailed   int
+	tasksExpired  int
+	tasksRetried  int
+
+	clientsInvalidated int
+
+	// clientsBusy counts running tasks per client id, a client with parallelism runs several at once
+	clientsBusy map[string]int
+}
+
+func (self *effortParserCounters) taskStarted(client *EffortParserClient) {
+	self.tasksRunning++
+	if self.clientsBusy == nil {
+		self.clientsBusy = make(map[string]int)
+	}
+	self.clientsBusy[client.Id]++
+}
+
+func (self *effortParserCounters) taskStopped(client *EffortParserClient) {
+	self.tasksRunning--
+	if self.clientsBusy[client.Id]--; self.clientsBusy[client.Id] <= 0 {
+		delete(self.clientsBusy, client.Id)
+	}
+}
+
+func (self *EffortParser) SetEventHandler(handler EffortParserEventHandler) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.handler = handler
+}
+
+func (self *EffortParser) Stats() EffortParserStats {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	stats := EffortParserStats{
+		StartedAt: self.counters.startedAt,
+
+		TasksLeft:     self.tasksLeft,
+		TasksQueued:   self.tasks.len(),
+		TasksRunning:  self.counters.tasksRunning,
+		TasksFinished: self.counters.tasksFinished,
+		TasksFailed:   self.counters.tasksFailed,
+		TasksExpired:  self.counters.tasksExpired,
+		TasksRetried:  self.counters.tasksRetried,
+
+		ClientsActive:      len(self.clientIds),
+		ClientsBusy:        len(self.counters.clientsBusy),
+		ClientsCooling:     self.coolingClientsCount(),
+		ClientsInvalidated: self.counters.clientsInvalidated,
+	}
+
+	if !stats.StartedAt.IsZero() {
+		stats.Elapsed = time.Since(stats.StartedAt)
+	}
+
+	return stats
+}
+
+func (self *EffortParser) coolingClientsCount() int {
+	count := 0
+	for _, client := range self.clientIds {
+		if client.coolingFor() > 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (self *EffortParser) emit(eventType EffortParserEventType, task *EffortParserTask, client *EffortParserClient, err error) {
+	self.mu.Lock()
+	switch eventType {
+	case EffortTaskStarted:
+		self.counters.taskStarted(client)
+	case EffortTaskFinished:
+		self.counters.tasksFinished++
+	case EffortTaskRetried:
+		self.counters.tasksRetried++
+	case EffortTaskFailed:
+		self.counters.tasksFailed++
+	case EffortTaskExpired:
+		self.counters.tasksExpired++
+	case EffortClientInvalidated:
+		self.counters.clientsInvalidated++
+	}
+	handler := self.handler
+	self.mu.Unlock()
+
+	if handler == nil {
+		return
+	}
+
+	handler(&EffortParserEvent{
+		Type:   eventType,
+		Time:   time.Now(),
+		Task:   task,
+		Client: client,
+		Err:    err,
+	})
+}
@@ -0,0 +1,217 @@
+package iglocparser
+
+import (
+	"encoding/json"
+	"github.com/ansel1/merry"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type EffortTaskRecord struct {
+	Id              string               `json:"id"`
+	Data            json.RawMessage      `json:"data"`
+	AttemptsLeft    int                  `json:"attempts_left"`
+	ExcludedClients []string             `json:"excluded_clients,omitempty"`
+	History         []*EffortTaskAttempt `json:"history,omitempty"`
+	Priority        int                  `json:"priority,omitempty"`
+	Deadline        time.Time            `json:"deadline"`
+}
+
+type EffortTaskStore interface {
+	SaveTask(record *EffortTaskRecord) error
+	DeleteTask(id string) error
+	LoadTasks() ([]*EffortTaskRecord, error)
+}
+
+func (self *EffortParserTask) record() (*EffortTaskRecord, error) {
+	data, err := json.Marshal(self.Data)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	record := &EffortTaskRecord{
+		Id:           self.Id,
+		Data:         data,
+		AttemptsLeft: self.attemptsLeft,
+		Priority:     self.priority,
+		Deadline:     self.deadline,
+	}
+
+	for id := range self.utilizeClients {
+		record.ExcludedClients = append(record.ExcludedClients, id)
+	}
+	sort.Strings(record.ExcludedClients)
+
+	record.History = append(record.History, self.history...)
+
+	return record, nil
+}
+
+func (self *EffortTaskRecord) task(decode func(data json.RawMessage) (interface{}, error)) (*EffortParserTask, error) {
+	var data interface{} = self.Data
+	if decode != nil {
+		var err error
+		if data, err = decode(self.Data); err != nil {
+			return nil, merry.Wrap(err)
+		}
+	}
+
+	task := NewEffortParserTask(data, self.AttemptsLeft)
+	task.Id = self.Id
+	task.priority = self.Priority
+	task.deadline = self.Deadline
+	task.history = self.History
+
+	for _, id := range self.ExcludedClients {
+		if task.utilizeClients == nil {
+			task.utilizeClients = make(map[string]struct{})
+		}
+
+		task.utilizeClients[id] = struct{}{}
+	}
+
+	return task, nil
+}
+
+const fileEffortTaskStoreExt = ".json"
+
+var regexEffortTaskId = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// Task ids end up in file names, so only ids made of letters, digits, "_" and "-" are accepted.
+func validateEffortTaskId(id string) error {
+	if !regexEffortTaskId.MatchString(id) {
+		return merry.Appendf(ErrInvalidTaskId, "id %q", id)
+	}
+
+	return nil
+}
+
+func effortJsonFilePath(dir string, id string) (string, error) {
+	if err := validateEffortTaskId(id); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, id+fileEffortTaskStoreExt), nil
+}
+
+type FileEffortTaskStore struct {
+	dir string
+}
+
+func NewFileEffortTaskStore(dir string) (*FileEffortTaskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return &FileEffortTaskStore{
+		dir: dir,
+	}, nil
+}
+
+func (self *FileEffortTaskStore) SaveTask(record *EffortTaskRecord) error {
+	return writeJsonFileAtomic(self.dir, record.Id, record)
+}
+
+func (self *FileEffortTaskStore) DeleteTask(id string) error {
+	path, err := effortJsonFilePath(self.dir, id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return merry.Wrap(err)
+	}
+
+	return nil
+}
+
+func (self *FileEffortTaskStore) LoadTasks() ([]*EffortTaskRecord, error) {
+	var records []*EffortTaskRecord
+	err := readJsonFiles(self.dir, func(body []byte) error {
+		record := &EffortTaskRecord{}
+		if err := json.Unmarshal(body, record); err != nil {
+			return merry.Wrap(err)
+		}
+
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func writeJsonFileAtomic(dir string, id string, v interface{}) error {
+	path, err := effortJsonFilePath(dir, id)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	tmp, err := ioutil.TempFile(dir, id+".*.tmp")
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return merry.Wrap(err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return merry.Wrap(err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return merry.Wrap(err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return merry.Wrap(err)
+	}
+
+	return nil
+}
+
+func readJsonFiles(dir string, fn func(body []byte) error) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileEffortTaskStoreExt) {
+			continue
+		}
+
+		body, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return merry.Wrap(err)
+		}
+
+		if err := fn(body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
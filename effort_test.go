@@ -0,0 +1,927 @@
+package iglocparser
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ansel1/merry"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestClients(n int) []*Client {
+	clients := make([]*Client, n)
+	for i := range clients {
+		clients[i] = &Client{}
+	}
+
+	return clients
+}
+
+func runEffortParser(t *testing.T, parser *EffortParser) error {
+	t.Helper()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- parser.Run()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("effort parser did not finish in time")
+	}
+
+	return nil
+}
+
+type effortTestLog struct {
+	mu   sync.Mutex
+	data []interface{}
+}
+
+func (self *effortTestLog) add(data interface{}) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.data = append(self.data, data)
+}
+
+func (self *effortTestLog) get() []interface{} {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return append([]interface{}(nil), self.data...)
+}
+
+func assertEffortTestLog(t *testing.T, log *effortTestLog, expected ...interface{}) {
+	t.Helper()
+
+	got := log.get()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestEffortParserPriority(t *testing.T) {
+	log := &effortTestLog{}
+	parser := NewOpenEffortParser(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(task.Data)
+		return true
+	})
+
+	for i, priority := range []int{0, 5, -1, 5, 10} {
+		task := NewEffortParserTask(i, 1)
+		task.SetPriority(priority)
+		if err := parser.SubmitTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parser.CloseTasks()
+
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEffortTestLog(t, log, 4, 1, 3, 0, 2)
+}
+
+func TestEffortParserSetPriorityQueued(t *testing.T) {
+	log := &effortTestLog{}
+	parser := NewOpenEffortParser(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(task.Data)
+		return true
+	})
+
+	var tasks []*EffortParserTask
+	for i := 0; i < 3; i++ {
+		task, err := parser.AddTask(i, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tasks = append(tasks, task)
+	}
+
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func(task *EffortParserTask, priority int) {
+			defer wg.Done()
+			task.SetPriority(priority)
+		}(task, i)
+	}
+	wg.Wait()
+	parser.CloseTasks()
+
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEffortTestLog(t, log, 2, 1, 0)
+}
+
+func TestEffortParserSetPriorityWhileRunning(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	log := &effortTestLog{}
+	parser := NewOpenEffortParser(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		if task.Data == "first" {
+			close(started)
+			<-release
+		}
+
+		log.add(task.Data)
+		return true
+	})
+
+	if _, err := parser.AddTask("first", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- parser.Run()
+	}()
+	<-started
+
+	low, err := parser.AddTask("low", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	high, err := parser.AddTask("high", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Run may already hold one of the two tasks while it waits for the
+	// only client, so only the relative order is checked.
+	high.SetPriority(10)
+	low.SetPriority(-10)
+	parser.CloseTasks()
+	close(release)
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("effort parser did not finish in time")
+	}
+
+	if got := log.get(); len(got) != 3 || got[0] != "first" {
+		t.Fatalf("unexpected order %v", got)
+	}
+}
+
+func TestEffortParserDeadline(t *testing.T) {
+	log := &effortTestLog{}
+	parser := NewOpenEffortParser(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(task.Data)
+		return true
+	})
+
+	var expired []interface{}
+	parser.SetEventHandler(func(event *EffortParserEvent) {
+		if event.Type == EffortTaskExpired {
+			expired = append(expired, event.Task.Data)
+		}
+	})
+
+	stale := NewEffortParserTask("stale", 1)
+	stale.SetDeadline(time.Now().Add(-time.Second))
+	fresh := NewEffortParserTask("fresh", 1)
+	fresh.SetDeadline(time.Now().Add(time.Hour))
+
+	for _, task := range []*EffortParserTask{stale, fresh} {
+		if err := parser.SubmitTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parser.CloseTasks()
+
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEffortTestLog(t, log, "fresh")
+	if len(expired) != 1 || expired[0] != "stale" {
+		t.Fatalf("expected stale task to expire, got %v", expired)
+	}
+
+	if stats := parser.Stats(); stats.TasksExpired != 1 || stats.TasksFinished != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEffortParserRetryPriorityStep(t *testing.T) {
+	log := &effortTestLog{}
+	parser := NewOpenEffortParser(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(task.Data)
+		if task.Data == "flaky" && task.AttemptsDecrease() > 0 {
+			return false
+		}
+
+		return true
+	})
+	parser.SetRetryPriorityStep(1)
+
+	for _, data := range []string{"flaky", "a", "b"} {
+		if _, err := parser.AddTask(data, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parser.CloseTasks()
+
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEffortTestLog(t, log, "flaky", "a", "b", "flaky")
+}
+
+func decodeEffortTestString(data json.RawMessage) (interface{}, error) {
+	var s string
+	err := json.Unmarshal(data, &s)
+	return s, err
+}
+
+func TestEffortParserStoreResume(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileEffortTaskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crashed := make(chan string, 1)
+	first, err := NewOpenEffortParserWithOptions(newTestClients(2), func(client *EffortParserClient, task *EffortParserTask) bool {
+		if task.AttemptsLeft() == 3 {
+			task.DontUseClient(client)
+			task.AttemptsDecrease()
+			return false
+		}
+
+		// simulate a crash while the retry runs, the goroutine is never released
+		crashed <- client.Id
+		select {}
+	}, &EffortParserOptions{
+		ClientIds: []string{"http://proxy-a", "http://proxy-b"},
+		Store:     store,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := first.AddTask("place", 3); err != nil {
+		t.Fatal(err)
+	}
+	go first.Run()
+
+	var usedId string
+	select {
+	case usedId = <-crashed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task was not retried")
+	}
+
+	excludedId := "http://proxy-a"
+	if usedId == excludedId {
+		excludedId = "http://proxy-b"
+	}
+
+	records, err := store.LoadTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].AttemptsLeft != 2 || len(records[0].ExcludedClients) != 1 || records[0].ExcludedClients[0] != excludedId {
+		t.Fatalf("unexpected stored records %+v", records)
+	}
+
+	// the restarted process lists its proxies in another order and adds a new one
+	log := &effortTestLog{}
+	second, err := NewEffortParserWithOptions(newTestClients(3), nil, 0, func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(task.AttemptsLeft())
+		if task.Data != "place" {
+			t.Errorf("unexpected resumed task %v", task.Data)
+		}
+
+		if client.Id == excludedId {
+			t.Errorf("resumed task ran on excluded client %s", client.Id)
+		}
+
+		task.DontUseClient(client)
+		task.AttemptsDecrease()
+		return false
+	}, &EffortParserOptions{
+		ClientIds: []string{"http://proxy-c", "http://proxy-b", "http://proxy-a"},
+		Store:     store,
+		Decode:    decodeEffortTestString,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runEffortParser(t, second); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEffortTestLog(t, log, 2, 1)
+
+	if records, err := store.LoadTasks(); err != nil || len(records) != 0 {
+		t.Fatalf("expected store to be empty, got %v %v", records, err)
+	}
+}
+
+func TestEffortParserStoreConstructorTasks(t *testing.T) {
+	store, err := NewFileEffortTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	parser, err := NewEffortParserWithOptions(newTestClients(1), []interface{}{"a", "b"}, 1, func(client *EffortParserClient, task *EffortParserTask) bool {
+		<-release
+		return true
+	}, &EffortParserOptions{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := store.LoadTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected constructor tasks to be stored before Run, got %d", len(records))
+	}
+
+	close(release)
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+
+	if records, err := store.LoadTasks(); err != nil || len(records) != 0 {
+		t.Fatalf("expected store to be empty, got %v %v", records, err)
+	}
+}
+
+func TestEffortParserClientIds(t *testing.T) {
+	fn := func(client *EffortParserClient, task *EffortParserTask) bool {
+		return true
+	}
+
+	if _, err := NewOpenEffortParserWithOptions(newTestClients(2), fn, &EffortParserOptions{ClientIds: []string{"a"}}); err == nil {
+		t.Fatal("expected error for mismatched client ids")
+	}
+
+	if _, err := NewOpenEffortParserWithOptions(newTestClients(2), fn, &EffortParserOptions{ClientIds: []string{"a", "a"}}); err == nil {
+		t.Fatal("expected error for duplicate client ids")
+	}
+
+	parser, err := NewOpenEffortParserWithOptions(newTestClients(1), fn, &EffortParserOptions{ClientIds: []string{"0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := parser.AddClientWithId("0", &Client{}); err == nil {
+		t.Fatal("expected error for duplicate client id")
+	}
+
+	parser.AddClient(&Client{})
+	if stats := parser.Stats(); stats.ClientsActive != 2 {
+		t.Fatalf("expected 2 active clients, got %d", stats.ClientsActive)
+	}
+}
+
+func TestEffortParserDeadLetterOnExhaustion(t *testing.T) {
+	deadLetters, err := NewFileEffortDeadLetterStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser, err := NewEffortParserWithOptions(newTestClients(2), []interface{}{"ok", "broken", "excluded"}, 3, func(client *EffortParserClient, task *EffortParserTask) bool {
+		switch task.Data {
+		case "broken":
+			task.AddError(errors.New("bad response"))
+			task.AttemptsDecrease()
+			return false
+		case "excluded":
+			task.DontUseClient(client)
+			return false
+		}
+
+		return true
+	}, &EffortParserOptions{
+		ClientIds:   []string{"a", "b"},
+		DeadLetters: deadLetters,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+
+	letters, err := deadLetters.LoadDeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(letters) != 2 {
+		t.Fatalf("expected 2 dead letters, got %d", len(letters))
+	}
+
+	byData := make(map[string]*EffortDeadLetter)
+	for _, letter := range letters {
+		var data string
+		if err := json.Unmarshal(letter.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+
+		byData[data] = letter
+		if letter.Err != ErrTaskExhausted.Error() {
+			t.Fatalf("unexpected dead letter error %q", letter.Err)
+		}
+	}
+
+	if letter := byData["broken"]; letter == nil || len(letter.History) != 3 || letter.History[0].Error != "bad response" {
+		t.Fatalf("unexpected dead letter for broken task %+v", letter)
+	}
+
+	if letter := byData["excluded"]; letter == nil || len(letter.Clients) != 2 {
+		t.Fatalf("unexpected dead letter for excluded task %+v", letter)
+	}
+
+	if stats := parser.Stats(); stats.TasksFailed != 2 || stats.TasksFinished != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	log := &effortTestLog{}
+	redrive := NewOpenEffortParser(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(task.Data)
+		return true
+	})
+
+	count, err := redrive.RedriveDeadLetters(deadLetters, 1, decodeEffortTestString)
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 redriven tasks, got %d %v", count, err)
+	}
+	redrive.CloseTasks()
+
+	if err := runEffortParser(t, redrive); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := log.get(); len(got) != 2 {
+		t.Fatalf("expected redriven tasks to run, got %v", got)
+	}
+
+	if letters, err := deadLetters.LoadDeadLetters(); err != nil || len(letters) != 0 {
+		t.Fatalf("expected no dead letters after redrive, got %v %v", letters, err)
+	}
+}
+
+func TestEffortParserRejectsUnsafeTaskId(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileEffortTaskStore(filepath.Join(dir, "tasks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser, err := NewOpenEffortParserWithOptions(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		return true
+	}, &EffortParserOptions{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"../x", "a/b", "..", "a.b", " "} {
+		task := NewEffortParserTask("data", 1)
+		task.Id = id
+		if err := parser.SubmitTask(task); !merry.Is(err, ErrInvalidTaskId) {
+			t.Fatalf("expected invalid task id error for %q, got %v", id, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "x.json")); !os.IsNotExist(err) {
+		t.Fatalf("task was written outside the store directory: %v", err)
+	}
+
+	task := NewEffortParserTask("data", 1)
+	task.Id = "place_123-a"
+	if err := parser.SubmitTask(task); err != nil {
+		t.Fatal(err)
+	}
+
+	deadLetters, err := NewFileEffortDeadLetterStore(filepath.Join(dir, "dead"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := deadLetters.PutDeadLetter(&EffortDeadLetter{Id: "../x"}); !merry.Is(err, ErrInvalidTaskId) {
+		t.Fatalf("expected invalid task id error, got %v", err)
+	}
+}
+
+func TestEffortParserCooldown(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+
+	type attempt struct {
+		clientId string
+		time     time.Time
+	}
+
+	var parser *EffortParser
+	var mu sync.Mutex
+	var attempts []attempt
+	var coolingStats EffortParserStats
+	parser = NewOpenEffortParser(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		mu.Lock()
+		attempts = append(attempts, attempt{client.Id, time.Now()})
+		mu.Unlock()
+
+		if task.AttemptsDecrease() > 0 {
+			client.Cooldown(cooldown)
+			return false
+		}
+
+		return true
+	})
+
+	parser.SetEventHandler(func(event *EffortParserEvent) {
+		if event.Type == EffortClientCooledDown {
+			mu.Lock()
+			coolingStats = parser.Stats()
+			mu.Unlock()
+		}
+	})
+
+	if _, err := parser.AddTask("place", 2); err != nil {
+		t.Fatal(err)
+	}
+	parser.CloseTasks()
+
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(attempts) != 2 || attempts[0].clientId != attempts[1].clientId {
+		t.Fatalf("expected two attempts on the same client, got %+v", attempts)
+	}
+
+	if d := attempts[1].time.Sub(attempts[0].time); d < cooldown {
+		t.Fatalf("client was reused after %s, before its %s cooldown", d, cooldown)
+	}
+
+	if coolingStats.ClientsCooling != 1 || coolingStats.ClientsActive != 1 {
+		t.Fatalf("unexpected stats during cooldown %+v", coolingStats)
+	}
+
+	if stats := parser.Stats(); stats.ClientsCooling != 0 || stats.ClientsInvalidated != 0 || stats.TasksRetried != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEffortParserAddClient(t *testing.T) {
+	var parser *EffortParser
+	log := &effortTestLog{}
+	parser = NewOpenEffortParser(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(client.Id)
+		if client.Id == "0" {
+			client.Invalidate()
+			parser.AddClient(&Client{})
+			return false
+		}
+
+		return true
+	})
+
+	if _, err := parser.AddTask("place", 2); err != nil {
+		t.Fatal(err)
+	}
+	parser.CloseTasks()
+
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEffortTestLog(t, log, "0", "1")
+	if stats := parser.Stats(); stats.ClientsActive != 1 || stats.ClientsInvalidated != 1 || stats.TasksFinished != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEffortParserClientsExceed(t *testing.T) {
+	parser := NewEffortParser(newTestClients(1), []interface{}{"a", "b"}, 1, func(client *EffortParserClient, task *EffortParserTask) bool {
+		client.Invalidate()
+		return false
+	})
+
+	if err := runEffortParser(t, parser); err != ErrClientsExceed {
+		t.Fatalf("expected ErrClientsExceed, got %v", err)
+	}
+}
+
+func TestEffortParserAddTaskWhileRunning(t *testing.T) {
+	log := &effortTestLog{}
+	parser := NewOpenEffortParser(newTestClients(2), func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(task.Data)
+		if city, ok := task.Data.(string); ok {
+			for i := 0; i < 3; i++ {
+				if _, err := task.Parser().AddTask(len(city)*10+i, 1); err != nil {
+					t.Errorf("failed to add follow-up task: %v", err)
+				}
+			}
+		}
+
+		return true
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- parser.Run()
+	}()
+
+	select {
+	case <-parser.Done():
+		t.Fatal("open parser finished without CloseTasks")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	for _, city := range []string{"paris", "rome"} {
+		if _, err := parser.AddTask(city, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// tasks stay open for follow-ups after CloseTasks until every task is done
+	parser.CloseTasks()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("effort parser did not finish in time")
+	}
+
+	if got := log.get(); len(got) != 8 {
+		t.Fatalf("expected 2 cities and 6 follow-ups, got %v", got)
+	}
+
+	if _, err := parser.AddTask("late", 1); err != ErrTasksClosed {
+		t.Fatalf("expected ErrTasksClosed, got %v", err)
+	}
+
+	if stats := parser.Stats(); stats.TasksLeft != 0 || stats.TasksFinished != 8 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEffortParserCloseTasksEmpty(t *testing.T) {
+	parser := NewOpenEffortParser(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		return true
+	})
+
+	parser.CloseTasks()
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEffortParserExcludedLastClient(t *testing.T) {
+	parser, err := NewEffortParserWithOptions(newTestClients(2), []interface{}{"excluding", "invalidating"}, 10, func(client *EffortParserClient, task *EffortParserTask) bool {
+		if task.Data == "excluding" {
+			task.DontUseClient(client)
+			return false
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		client.Invalidate()
+		return true
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := parser.Stats(); stats.TasksLeft != 0 || stats.TasksFailed != 1 || stats.TasksFinished != 1 || stats.ClientsActive != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEffortParserReplenishOnInvalidated(t *testing.T) {
+	var parser *EffortParser
+	log := &effortTestLog{}
+	parser = NewEffortParser(newTestClients(1), []interface{}{"a", "b"}, 2, func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(client.Id)
+		if client.Id == "0" {
+			client.Invalidate()
+			return false
+		}
+
+		return true
+	})
+
+	parser.SetEventHandler(func(event *EffortParserEvent) {
+		if event.Type == EffortClientInvalidated {
+			// a slow replenish, e.g. fetching a fresh proxy, must not lose the race with Run
+			time.Sleep(20 * time.Millisecond)
+			parser.AddClient(&Client{})
+		}
+	})
+
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := parser.Stats(); stats.TasksFinished != 2 || stats.ClientsActive != 1 || stats.ClientsInvalidated != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+type failingEffortTaskStore struct {
+	EffortTaskStore
+
+	mu      sync.Mutex
+	isFail  bool
+	failErr error
+}
+
+func (self *failingEffortTaskStore) setFail(isFail bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.isFail = isFail
+}
+
+func (self *failingEffortTaskStore) SaveTask(record *EffortTaskRecord) error {
+	self.mu.Lock()
+	isFail := self.isFail
+	self.mu.Unlock()
+
+	if isFail {
+		return self.failErr
+	}
+
+	return self.EffortTaskStore.SaveTask(record)
+}
+
+func TestEffortParserStopsOnStoreError(t *testing.T) {
+	files, err := NewFileEffortTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &failingEffortTaskStore{EffortTaskStore: files, failErr: errors.New("disk full")}
+	log := &effortTestLog{}
+	parser, err := NewEffortParserWithOptions(newTestClients(1), []interface{}{"retry", "b", "c", "d"}, 2, func(client *EffortParserClient, task *EffortParserTask) bool {
+		log.add(task.Data)
+		if task.Data == "retry" {
+			// the retry cannot be persisted
+			store.setFail(true)
+			task.AttemptsDecrease()
+			return false
+		}
+
+		return true
+	}, &EffortParserOptions{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runEffortParser(t, parser); err != store.failErr {
+		t.Fatalf("expected the store error, got %v", err)
+	}
+
+	if got := log.get(); len(got) > 2 {
+		t.Fatalf("expected Run to stop after the store error, ran %v", got)
+	}
+
+	if records, err := files.LoadTasks(); err != nil || len(records) < 3 {
+		t.Fatalf("expected unfinished tasks to stay stored, got %d %v", len(records), err)
+	}
+}
+
+func TestEffortParserStoreConstructorRestart(t *testing.T) {
+	fn := func(client *EffortParserClient, task *EffortParserTask) bool {
+		return true
+	}
+
+	for _, taskId := range []func(data interface{}) string{nil, func(data interface{}) string { return data.(string) }} {
+		store, err := NewFileEffortTaskStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		opts := &EffortParserOptions{Store: store, Decode: decodeEffortTestString, TaskId: taskId}
+		if _, err := NewEffortParserWithOptions(newTestClients(1), []interface{}{"a", "b"}, 1, fn, opts); err != nil {
+			t.Fatal(err)
+		}
+
+		// the process restarts before running and repeats the same constructor call
+		parser, err := NewEffortParserWithOptions(newTestClients(1), []interface{}{"a", "b"}, 1, fn, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stats := parser.Stats(); stats.TasksLeft != 2 {
+			t.Fatalf("expected 2 tasks after restart, got %d", stats.TasksLeft)
+		}
+
+		if records, err := store.LoadTasks(); err != nil || len(records) != 2 {
+			t.Fatalf("expected 2 stored tasks, got %d %v", len(records), err)
+		}
+	}
+
+	store, err := NewFileEffortTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// with stable ids the tasks a crashed run never stored are still added
+	opts := &EffortParserOptions{Store: store, Decode: decodeEffortTestString, TaskId: func(data interface{}) string { return data.(string) }}
+	if _, err := NewEffortParserWithOptions(newTestClients(1), []interface{}{"a", "a"}, 1, fn, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	parser, err := NewEffortParserWithOptions(newTestClients(1), []interface{}{"a", "b", "c"}, 1, fn, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := parser.Stats(); stats.TasksLeft != 3 {
+		t.Fatalf("expected 3 tasks, got %d", stats.TasksLeft)
+	}
+
+	if err := runEffortParser(t, parser); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEffortParserStatsClientsBusy(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	parser, err := NewOpenEffortParserWithOptions(newTestClients(1), func(client *EffortParserClient, task *EffortParserTask) bool {
+		started <- struct{}{}
+		<-release
+		return true
+	}, &EffortParserOptions{ClientParallelism: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []string{"a", "b"} {
+		if _, err := parser.AddTask(data, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parser.CloseTasks()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- parser.Run()
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("tasks did not start")
+		}
+	}
+
+	if stats := parser.Stats(); stats.TasksRunning != 2 || stats.ClientsBusy != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	close(release)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := parser.Stats(); stats.TasksRunning != 0 || stats.ClientsBusy != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
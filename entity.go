@@ -0,0 +1,142 @@
+package iglocparser
+
+import (
+	"fmt"
+)
+
+type EntityKind int
+
+const (
+	EntityKindCountry EntityKind = iota + 1
+	EntityKindCity
+	EntityKindLocation
+)
+
+func (self EntityKind) String() string {
+	switch self {
+	case EntityKindCountry:
+		return "country"
+	case EntityKindCity:
+		return "city"
+	case EntityKindLocation:
+		return "location"
+	}
+
+	return "unknown"
+}
+
+type Entity interface {
+	GetId() string
+	GetName() string
+	GetSlug() string
+	Kind() EntityKind
+	Parent() Entity
+	URL() string
+}
+
+func (self *Country) GetId() string {
+	return self.Id
+}
+
+func (self *Country) GetName() string {
+	return self.Name
+}
+
+func (self *Country) GetSlug() string {
+	return self.Slug
+}
+
+func (self *Country) Kind() EntityKind {
+	return EntityKindCountry
+}
+
+func (self *Country) Parent() Entity {
+	return nil
+}
+
+func (self *City) GetId() string {
+	return self.Id
+}
+
+func (self *City) GetName() string {
+	return self.Name
+}
+
+func (self *City) GetSlug() string {
+	return self.Slug
+}
+
+func (self *City) Kind() EntityKind {
+	return EntityKindCity
+}
+
+func (self *City) Parent() Entity {
+	if self.Country == nil {
+		return nil
+	}
+
+	return self.Country
+}
+
+func (self *Location) GetId() string {
+	return self.Id
+}
+
+func (self *Location) GetName() string {
+	return self.Name
+}
+
+func (self *Location) GetSlug() string {
+	return self.Slug
+}
+
+func (self *Location) Kind() EntityKind {
+	return EntityKindLocation
+}
+
+func (self *Location) Parent() Entity {
+	return getLocationParent(self.City, self.Country)
+}
+
+func (self *Place) GetId() string {
+	return self.Id
+}
+
+func (self *Place) GetName() string {
+	return self.Name
+}
+
+func (self *Place) GetSlug() string {
+	return self.Slug
+}
+
+func (self *Place) Kind() EntityKind {
+	return EntityKindLocation
+}
+
+func (self *Place) Parent() Entity {
+	return getLocationParent(self.City, self.Country)
+}
+
+func getLocationParent(city *City, country *Country) Entity {
+	if city != nil {
+		return city
+	} else if country != nil {
+		return country
+	}
+
+	return nil
+}
+
+func getEntityString(entity Entity) string {
+	return fmt.Sprintf("[id=%v; name=%v; slug=%v]", entity.GetId(), entity.GetName(), entity.GetSlug())
+}
+
+func EntityPath(entity Entity) []Entity {
+	var path []Entity
+	for e := entity; e != nil; e = e.Parent() {
+		path = append([]Entity{e}, path...)
+	}
+
+	return path
+}
@@ -0,0 +1,296 @@
+package iglocparser
+
+import (
+	"fmt"
+	"github.com/ansel1/merry"
+)
+
+var DefaultGeoGridSize = 4
+var DefaultGeoGridMaxDepth = 4
+var DefaultGeoGridSubdivideThreshold = 25
+
+type LatLng struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+type BoundingBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLng float64 `json:"min_lng"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLng float64 `json:"max_lng"`
+}
+
+func (self BoundingBox) String() string {
+	return fmt.Sprintf("[%v,%v - %v,%v]", self.MinLat, self.MinLng, self.MaxLat, self.MaxLng)
+}
+
+func (self BoundingBox) Center() LatLng {
+	return LatLng{
+		Lat: (self.MinLat + self.MaxLat) / 2,
+		Lng: (self.MinLng + self.MaxLng) / 2,
+	}
+}
+
+func (self BoundingBox) Contains(p LatLng) bool {
+	return p.Lat >= self.MinLat && p.Lat <= self.MaxLat && p.Lng >= self.MinLng && p.Lng <= self.MaxLng
+}
+
+func (self BoundingBox) Grid(rows, cols int) []BoundingBox {
+	if rows <= 0 {
+		rows = 1
+	}
+
+	if cols <= 0 {
+		cols = 1
+	}
+
+	latStep := (self.MaxLat - self.MinLat) / float64(rows)
+	lngStep := (self.MaxLng - self.MinLng) / float64(cols)
+
+	cells := make([]BoundingBox, 0, rows*cols)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			cells = append(cells, BoundingBox{
+				MinLat: self.MinLat + latStep*float64(r),
+				MinLng: self.MinLng + lngStep*float64(c),
+				MaxLat: self.MinLat + latStep*float64(r+1),
+				MaxLng: self.MinLng + lngStep*float64(c+1),
+			})
+		}
+	}
+
+	return cells
+}
+
+func (self BoundingBox) corners() []LatLng {
+	return []LatLng{
+		{self.MinLat, self.MinLng},
+		{self.MinLat, self.MaxLng},
+		{self.MaxLat, self.MinLng},
+		{self.MaxLat, self.MaxLng},
+	}
+}
+
+type Polygon []LatLng
+
+func (self Polygon) Bounds() BoundingBox {
+	if len(self) == 0 {
+		return BoundingBox{}
+	}
+
+	box := BoundingBox{
+		MinLat: self[0].Lat,
+		MinLng: self[0].Lng,
+		MaxLat: self[0].Lat,
+		MaxLng: self[0].Lng,
+	}
+
+	for _, p := range self[1:] {
+		if p.Lat < box.MinLat {
+			box.MinLat = p.Lat
+		}
+		if p.Lat > box.MaxLat {
+			box.MaxLat = p.Lat
+		}
+		if p.Lng < box.MinLng {
+			box.MinLng = p.Lng
+		}
+		if p.Lng > box.MaxLng {
+			box.MaxLng = p.Lng
+		}
+	}
+
+	return box
+}
+
+func (self Polygon) Contains(p LatLng) bool {
+	inside := false
+	for i, j := 0, len(self)-1; i < len(self); j, i = i, i+1 {
+		a, b := self[i], self[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lng < (b.Lng-a.Lng)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+func (self Polygon) intersects(box BoundingBox) bool {
+	if self.Contains(box.Center()) {
+		return true
+	}
+
+	for _, corner := range box.corners() {
+		if self.Contains(corner) {
+			return true
+		}
+	}
+
+	for _, p := range self {
+		if box.Contains(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type GeoGridOptions struct {
+	Rows               int
+	Cols               int
+	MaxDepth           int
+	SubdivideThreshold int
+	Query              string
+	Polygon            Polygon
+	// Known matches candidates by Instagram location id. Nearby search often returns only a
+	// Facebook place id, those candidates are matched against KnownFacebookPlacesIds instead.
+	Known                  []*Location
+	KnownFacebookPlacesIds []string
+}
+
+func geoGridInstagramKey(id string) string {
+	return "ig:" + id
+}
+
+func geoGridFacebookPlacesKey(id string) string {
+	return "fb:" + id
+}
+
+func geoGridCandidateKeys(c *LocationCandidate) []string {
+	var keys []string
+	if c.Id != "" {
+		keys = append(keys, geoGridInstagramKey(c.Id))
+	}
+	if c.FacebookPlacesId != "" {
+		keys = append(keys, geoGridFacebookPlacesKey(c.FacebookPlacesId))
+	}
+
+	return keys
+}
+
+type geoGridDiscovery struct {
+	rotator  *IgApiClientRotator
+	opts     *GeoGridOptions
+	seen     map[string]struct{}
+	found    []*LocationCandidate
+	callback func(cell BoundingBox, depth int, found []*LocationCandidate)
+}
+
+func DiscoverLocations(rotator *IgApiClientRotator, box BoundingBox, opts *GeoGridOptions, callback func(cell BoundingBox, depth int, found []*LocationCandidate)) ([]*LocationCandidate, error) {
+	if opts == nil {
+		opts = &GeoGridOptions{}
+	}
+
+	d := &geoGridDiscovery{
+		rotator:  rotator,
+		opts:     opts,
+		seen:     make(map[string]struct{}),
+		callback: callback,
+	}
+
+	for _, l := range opts.Known {
+		if l != nil && l.Id != "" {
+			d.seen[geoGridInstagramKey(l.Id)] = struct{}{}
+		}
+	}
+
+	for _, id := range opts.KnownFacebookPlacesIds {
+		if id != "" {
+			d.seen[geoGridFacebookPlacesKey(id)] = struct{}{}
+		}
+	}
+
+	rows, cols := opts.Rows, opts.Cols
+	if rows <= 0 {
+		rows = DefaultGeoGridSize
+	}
+	if cols <= 0 {
+		cols = DefaultGeoGridSize
+	}
+
+	// A failed search stops the discovery, what was found before it is still returned.
+	for _, cell := range box.Grid(rows, cols) {
+		if err := d.discover(cell, 0); err != nil {
+			return d.found, merry.Wrap(err)
+		}
+	}
+
+	return d.found, nil
+}
+
+func DiscoverLocationsInPolygon(rotator *IgApiClientRotator, polygon Polygon, opts *GeoGridOptions, callback func(cell BoundingBox, depth int, found []*LocationCandidate)) ([]*LocationCandidate, error) {
+	o := GeoGridOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Polygon = polygon
+
+	return DiscoverLocations(rotator, polygon.Bounds(), &o, callback)
+}
+
+func (self *geoGridDiscovery) discover(cell BoundingBox, depth int) error {
+	if len(self.opts.Polygon) > 0 && !self.opts.Polygon.intersects(cell) {
+		return nil
+	}
+
+	center := cell.Center()
+	candidates, err := self.rotator.Next().SearchNearbyLocations(center.Lat, center.Lng, self.opts.Query)
+	if err != nil {
+		return merry.Appendf(err, "cell %v", cell)
+	}
+
+	var found []*LocationCandidate
+	for _, c := range candidates {
+		if len(self.opts.Polygon) > 0 && !self.opts.Polygon.Contains(LatLng{c.Latitude, c.Longitude}) {
+			continue
+		}
+
+		if self.isSeen(c) {
+			continue
+		}
+
+		for _, key := range geoGridCandidateKeys(c) {
+			self.seen[key] = struct{}{}
+		}
+		found = append(found, c)
+	}
+
+	self.found = append(self.found, found...)
+	if self.callback != nil {
+		self.callback(cell, depth, found)
+	}
+
+	threshold := self.opts.SubdivideThreshold
+	if threshold <= 0 {
+		threshold = DefaultGeoGridSubdivideThreshold
+	}
+
+	maxDepth := self.opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultGeoGridMaxDepth
+	}
+
+	if len(candidates) < threshold || depth >= maxDepth {
+		return nil
+	}
+
+	for _, child := range cell.Grid(2, 2) {
+		if err := self.discover(child, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *geoGridDiscovery) isSeen(c *LocationCandidate) bool {
+	for _, key := range geoGridCandidateKeys(c) {
+		if _, ok := self.seen[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
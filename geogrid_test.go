@@ -0,0 +1,69 @@
+package iglocparser
+
+import (
+	"net/http"
+	"testing"
+)
+
+func newTestIgApiClientRotator(t *testing.T, handler http.HandlerFunc) *IgApiClientRotator {
+	t.Helper()
+
+	client, _ := newTestServerClient(t, handler)
+	return NewIgApiClientRotator([]*IgApiClient{NewIgApiClient(NewAuthorizedClient(client, &IgApiCredentials{}))})
+}
+
+func TestDiscoverLocationsDedupe(t *testing.T) {
+	rotator := newTestIgApiClientRotator(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testLocationSearchJson))
+	})
+
+	box := BoundingBox{MinLat: 55, MinLng: 37, MaxLat: 56, MaxLng: 38}
+	found, err := DiscoverLocations(rotator, box, &GeoGridOptions{
+		Rows:  2,
+		Cols:  2,
+		Known: []*Location{{Id: "213385402"}, {Name: "no id"}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 1 || found[0].Name != "Far" {
+		t.Fatalf("got %v, want only the unknown Far candidate once", found)
+	}
+
+	found, err = DiscoverLocations(rotator, box, &GeoGridOptions{
+		Rows:                   2,
+		Cols:                   2,
+		KnownFacebookPlacesIds: []string{"106078429431815", "110589025627484"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 0 {
+		t.Fatalf("got %d candidates, want none", len(found))
+	}
+}
+
+func TestDiscoverLocationsKeepsFoundOnError(t *testing.T) {
+	calls := 0
+	rotator := newTestIgApiClientRotator(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte(testLocationSearchJson))
+	})
+
+	box := BoundingBox{MinLat: 55, MinLng: 37, MaxLat: 56, MaxLng: 38}
+	found, err := DiscoverLocations(rotator, box, &GeoGridOptions{Rows: 1, Cols: 2}, nil)
+	if err == nil {
+		t.Fatal("expected the second cell to fail")
+	}
+
+	if len(found) != 2 {
+		t.Fatalf("got %d candidates, want the 2 found before the failure", len(found))
+	}
+}
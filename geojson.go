@@ -0,0 +1,190 @@
+package iglocparser
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/ansel1/merry"
+	"io"
+)
+
+var ErrGeoJSONWriterClosed = errors.New("geojson writer is closed")
+
+type GeoJSONOptions struct {
+	SkipZeroCoordinates bool
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONPlaceProperties struct {
+	Name             string `json:"name"`
+	Slug             string `json:"slug"`
+	Blurb            string `json:"blurb,omitempty"`
+	Website          string `json:"website,omitempty"`
+	Phone            string `json:"phone,omitempty"`
+	PrimaryAliasOnFb string `json:"primary_alias_on_fb,omitempty"`
+	ProfilePicUrl    string `json:"profile_pic_url,omitempty"`
+	HasPublicPage    bool   `json:"has_public_page"`
+	Category         string `json:"category,omitempty"`
+	MediaCount       int    `json:"media_count"`
+	Url              string `json:"url"`
+
+	StreetAddress string `json:"street_address,omitempty"`
+	ZipCode       string `json:"zip_code,omitempty"`
+	CityName      string `json:"city_name,omitempty"`
+	RegionName    string `json:"region_name,omitempty"`
+	CountryCode   string `json:"country_code,omitempty"`
+
+	City    *City    `json:"city,omitempty"`
+	Country *Country `json:"country,omitempty"`
+}
+
+type geoJSONFeature struct {
+	Type       string                  `json:"type"`
+	Id         string                  `json:"id"`
+	Geometry   *geoJSONPoint           `json:"geometry"`
+	Properties *geoJSONPlaceProperties `json:"properties"`
+}
+
+type GeoJSONWriter struct {
+	w    *bufio.Writer
+	opts GeoJSONOptions
+
+	count    int
+	isClosed bool
+}
+
+func NewGeoJSONWriter(w io.Writer, opts *GeoJSONOptions) *GeoJSONWriter {
+	writer := &GeoJSONWriter{
+		w: bufio.NewWriter(w),
+	}
+
+	if opts != nil {
+		writer.opts = *opts
+	}
+
+	return writer
+}
+
+func (self *GeoJSONWriter) Count() int {
+	return self.count
+}
+
+func (self *GeoJSONWriter) Write(place *Place) error {
+	if self.isClosed {
+		return merry.Wrap(ErrGeoJSONWriterClosed)
+	}
+
+	if place == nil {
+		return nil
+	}
+
+	if self.opts.SkipZeroCoordinates && place.Latitude == 0 && place.Longitude == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(newGeoJSONFeature(place))
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	prefix := ","
+	if self.count == 0 {
+		prefix = `{"type":"FeatureCollection","features":[`
+	}
+
+	if _, err := self.w.WriteString(prefix); err != nil {
+		return merry.Wrap(err)
+	}
+
+	if _, err := self.w.Write(body); err != nil {
+		return merry.Wrap(err)
+	}
+
+	self.count++
+	return nil
+}
+
+func (self *GeoJSONWriter) Close() error {
+	if self.isClosed {
+		return nil
+	}
+	self.isClosed = true
+
+	suffix := "]}\n"
+	if self.count == 0 {
+		suffix = `{"type":"FeatureCollection","features":[]}` + "\n"
+	}
+
+	if _, err := self.w.WriteString(suffix); err != nil {
+		return merry.Wrap(err)
+	}
+
+	if err := self.w.Flush(); err != nil {
+		return merry.Wrap(err)
+	}
+
+	return nil
+}
+
+func newGeoJSONFeature(place *Place) *geoJSONFeature {
+	return &geoJSONFeature{
+		Type: "Feature",
+		Id:   place.Id,
+		Geometry: &geoJSONPoint{
+			Type:        "Point",
+			Coordinates: [2]float64{place.Longitude, place.Latitude},
+		},
+		Properties: &geoJSONPlaceProperties{
+			Name:             place.Name,
+			Slug:             place.Slug,
+			Blurb:            place.Blurb,
+			Website:          place.Website,
+			Phone:            place.Phone,
+			PrimaryAliasOnFb: place.PrimaryAliasOnFb,
+			ProfilePicUrl:    place.ProfilePicUrl,
+			HasPublicPage:    place.HasPublicPage,
+			Category:         place.Category,
+			MediaCount:       place.MediaCount,
+			Url:              place.URL(),
+
+			StreetAddress: place.Address.StreetAddress,
+			ZipCode:       place.Address.ZipCode,
+			CityName:      place.Address.CityName,
+			RegionName:    place.Address.RegionName,
+			CountryCode:   place.Address.CountryCode,
+
+			City:    place.City,
+			Country: place.Country,
+		},
+	}
+}
+
+func WritePlacesGeoJSON(w io.Writer, places []*Place, opts *GeoJSONOptions) error {
+	writer := NewGeoJSONWriter(w, opts)
+	for _, place := range places {
+		if err := writer.Write(place); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// StreamPlacesGeoJSON reads places until the channel is closed. After a write error the
+// rest of the channel is drained so the producer never blocks, the first error is returned.
+func StreamPlacesGeoJSON(w io.Writer, places <-chan *Place, opts *GeoJSONOptions) error {
+	writer := NewGeoJSONWriter(w, opts)
+	for place := range places {
+		if err := writer.Write(place); err != nil {
+			for range places {
+			}
+			return err
+		}
+	}
+
+	return writer.Close()
+}
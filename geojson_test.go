@@ -0,0 +1,61 @@
+package iglocparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testFailingWriter struct{}
+
+func (self testFailingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestStreamPlacesGeoJSONSkipsNil(t *testing.T) {
+	places := make(chan *Place, 3)
+	places <- &Place{Id: "1", Name: "a"}
+	places <- nil
+	places <- &Place{Id: "2", Name: "b"}
+	close(places)
+
+	buf := &bytes.Buffer{}
+	if err := StreamPlacesGeoJSON(buf, places, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	collection := struct {
+		Features []*geoJSONFeature `json:"features"`
+	}{}
+	if err := json.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(collection.Features) != 2 {
+		t.Fatalf("got %d features, want 2", len(collection.Features))
+	}
+}
+
+func TestStreamPlacesGeoJSONDrainsOnError(t *testing.T) {
+	places := make(chan *Place)
+	produced := make(chan struct{})
+	go func() {
+		defer close(produced)
+		defer close(places)
+		for i := 0; i < 10000; i++ {
+			places <- &Place{Id: "1", Name: "a"}
+		}
+	}()
+
+	if err := StreamPlacesGeoJSON(testFailingWriter{}, places, nil); err == nil {
+		t.Fatal("expected a write error")
+	}
+
+	select {
+	case <-produced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("producer is blocked")
+	}
+}
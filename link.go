@@ -0,0 +1,92 @@
+package iglocparser
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ansel1/merry"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidLocationUrl = errors.New("invalid instagram location url")
+
+var regexCountryId = regexp.MustCompile(`^[A-Z]{2}$`)
+var regexCityId = regexp.MustCompile(`^c\d+$`)
+var regexLocationId = regexp.MustCompile(`^\d+$`)
+
+func GetEntityKindById(id string) (EntityKind, bool) {
+	switch {
+	case regexCountryId.MatchString(id):
+		return EntityKindCountry, true
+	case regexCityId.MatchString(id):
+		return EntityKindCity, true
+	case regexLocationId.MatchString(id):
+		return EntityKindLocation, true
+	}
+
+	return 0, false
+}
+
+type LocationRef struct {
+	Kind EntityKind `json:"kind"`
+	Id   string     `json:"id"`
+	Slug string     `json:"slug,omitempty"`
+}
+
+func (self *LocationRef) String() string {
+	return fmt.Sprintf("[kind=%v; id=%v; slug=%v]", self.Kind, self.Id, self.Slug)
+}
+
+func (self *LocationRef) URL() string {
+	return GetIgLinkWithLeadingSlash(IgExploreLocationsPath, self.Id, self.Slug)
+}
+
+func ParseLocationUrl(link string) (*LocationRef, error) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return nil, merry.WithUserMessage(ErrInvalidLocationUrl, link)
+	}
+
+	// "instagram.com/explore/..." is a host without a scheme, "explore/locations/..." a relative path
+	if u.Host == "" && u.Scheme == "" && !strings.HasPrefix(u.Path, "/") {
+		if first := strings.SplitN(u.Path, "/", 2)[0]; strings.Contains(first, ".") {
+			if u, err = url.Parse("https://" + strings.TrimSpace(link)); err != nil {
+				return nil, merry.WithUserMessage(ErrInvalidLocationUrl, link)
+			}
+		}
+	}
+
+	if u.Host != "" {
+		host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+		if host != "instagram.com" {
+			return nil, merry.WithUserMessage(ErrInvalidLocationUrl, link)
+		}
+	}
+
+	path := strings.Trim(u.Path, "/")
+	if !strings.HasPrefix(path+"/", IgExploreLocationsPath+"/") {
+		return nil, merry.WithUserMessage(ErrInvalidLocationUrl, link)
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, IgExploreLocationsPath), "/"), "/")
+	if len(parts) == 0 || parts[0] == "" || len(parts) > 2 {
+		return nil, merry.WithUserMessage(ErrInvalidLocationUrl, link)
+	}
+
+	kind, ok := GetEntityKindById(parts[0])
+	if !ok {
+		return nil, merry.WithUserMessage(ErrInvalidLocationUrl, link)
+	}
+
+	ref := &LocationRef{
+		Kind: kind,
+		Id:   parts[0],
+	}
+
+	if len(parts) > 1 {
+		ref.Slug = parts[1]
+	}
+
+	return ref, nil
+}
@@ -0,0 +1,57 @@
+package iglocparser
+
+import (
+	"github.com/ansel1/merry"
+	"testing"
+)
+
+func TestParseLocationUrl(t *testing.T) {
+	tests := []struct {
+		link string
+		kind EntityKind
+		id   string
+		slug string
+	}{
+		{"https://www.instagram.com/explore/locations/RU/russia/", EntityKindCountry, "RU", "russia"},
+		{"https://instagram.com/explore/locations/RU/", EntityKindCountry, "RU", ""},
+		{"http://www.instagram.com/explore/locations/c2293237/moscow-russia", EntityKindCity, "c2293237", "moscow-russia"},
+		{"www.instagram.com/explore/locations/c2293237/", EntityKindCity, "c2293237", ""},
+		{"instagram.com/explore/locations/213385402/cafe", EntityKindLocation, "213385402", "cafe"},
+		{"https://WWW.Instagram.com/explore/locations/213385402/cafe/?hl=en", EntityKindLocation, "213385402", "cafe"},
+		{"/explore/locations/213385402/cafe/", EntityKindLocation, "213385402", "cafe"},
+		{"explore/locations/123/slug/", EntityKindLocation, "123", "slug"},
+		{"explore/locations/123", EntityKindLocation, "123", ""},
+		{"  https://www.instagram.com/explore/locations/RU/  ", EntityKindCountry, "RU", ""},
+	}
+
+	for _, test := range tests {
+		ref, err := ParseLocationUrl(test.link)
+		if err != nil {
+			t.Errorf("%q: %v", test.link, err)
+			continue
+		}
+
+		if ref.Kind != test.kind || ref.Id != test.id || ref.Slug != test.slug {
+			t.Errorf("%q: got %v", test.link, ref)
+		}
+	}
+}
+
+func TestParseLocationUrlRejected(t *testing.T) {
+	for _, link := range []string{
+		"",
+		"https://example.com/explore/locations/123/cafe/",
+		"https://www.instagram.com/p/abc/",
+		"https://www.instagram.com/explore/locations/",
+		"https://www.instagram.com/explore/locations/123/cafe/extra/",
+		"https://www.instagram.com/explore/locations/abc/",
+		"https://www.instagram.com/explore/locationsx/123/",
+		"explore/tags/cafe/",
+		"example.com/explore/locations/123/",
+		"://bad",
+	} {
+		if ref, err := ParseLocationUrl(link); !merry.Is(err, ErrInvalidLocationUrl) {
+			t.Errorf("%q: expected ErrInvalidLocationUrl, got %v %v", link, ref, err)
+		}
+	}
+}
@@ -2,18 +2,26 @@ package iglocparser
 
 import (
 	"encoding/json"
-	"fmt"
 	"github.com/ansel1/merry"
+	"io"
+	"io/ioutil"
 )
 
 type Location struct {
 	Id   string
 	Name string
 	Slug string
+
+	City    *City
+	Country *Country
 }
 
 func (self *Location) String() string {
-	return fmt.Sprintf("[id=%v; name=%v; slug=%v]", self.Id, self.Name, self.Slug)
+	return getEntityString(self)
+}
+
+func (self *Location) URL() string {
+	return GetIgLinkWithLeadingSlash(IgExploreLocationsPath, self.Id, self.Slug)
 }
 
 type igApiLocationsResponse struct {
@@ -39,13 +47,21 @@ func (self *IgApiLocationsCursor) Next(client *IgApiClient) ([]*Location, error)
 	link := GetIgLinkWithLeadingSlash(IgExploreLocationsPath, self.city.Id)
 	referrer := GetIgLinkWithLeadingSlash(IgExploreLocationsPath, self.city.Id, self.city.Slug)
 
-	var locations []*Location
-
 	body, err := client.do(link, self.nextPage, referrer)
 	if err != nil {
 		return nil, merry.Wrap(err)
 	}
 
+	res, err := decodeIgApiLocationsResponse(body)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	self.setNextPage(res.NextPage)
+	return res.locations(self.city), nil
+}
+
+func decodeIgApiLocationsResponse(body []byte) (*igApiLocationsResponse, error) {
 	res := &igApiLocationsResponse{}
 	if err := json.Unmarshal(body, res); err != nil {
 		return nil, merry.Wrap(err)
@@ -55,12 +71,54 @@ func (self *IgApiLocationsCursor) Next(client *IgApiClient) ([]*Location, error)
 		return nil, merry.WithUserMessage(ErrInvalidIgApiResponseCode, string(body))
 	}
 
-	for _, l := range res.LocationList {
+	return res, nil
+}
+
+func (self *igApiLocationsResponse) locations(city *City) []*Location {
+	if self.CityInfo != nil {
+		city = self.CityInfo
+	}
+
+	// city may be the caller's, it gets a copy with the country attached instead of being changed
+	country := self.CountryInfo
+	if city != nil {
+		if city.Country == nil && country != nil {
+			c := *city
+			c.Country = country
+			city = &c
+		} else if country == nil {
+			country = city.Country
+		}
+	}
+
+	var locations []*Location
+	for _, l := range self.LocationList {
+		if l.City == nil {
+			l.City = city
+		}
+
+		if l.Country == nil {
+			l.Country = country
+		}
+
 		locations = append(locations, l)
 	}
 
-	self.setNextPage(res.NextPage)
-	return locations, nil
+	return locations
+}
+
+func ParseLocationDirectory(r io.Reader) ([]*Location, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	res, err := decodeIgApiLocationsResponse(body)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return res.locations(nil), nil
 }
 
 func GetLocationsCursors(city *City) *IgApiLocationsCursor {
@@ -0,0 +1,33 @@
+package iglocparser
+
+import (
+	"testing"
+)
+
+func TestLocationsKeepCallerCity(t *testing.T) {
+	city := &City{Id: "c102", Name: "Moscow", Slug: "moscow"}
+	res := &igApiLocationsResponse{
+		LocationList: []*Location{{Id: "1", Name: "Cafe"}},
+		CountryInfo:  &Country{Id: "RU", Name: "Russia", Slug: "russia"},
+	}
+
+	locations := res.locations(city)
+	if city.Country != nil {
+		t.Fatalf("caller's city was changed: %+v", city.Country)
+	}
+
+	if len(locations) != 1 || locations[0].City == nil || locations[0].City.Id != "c102" {
+		t.Fatalf("unexpected locations %+v", locations)
+	}
+
+	if locations[0].City.Country == nil || locations[0].City.Country.Id != "RU" || locations[0].Country.Id != "RU" {
+		t.Fatalf("expected the country attached to the location and its city, got %+v", locations[0])
+	}
+
+	// a city with its own country lends it to locations
+	city.Country = &Country{Id: "RU"}
+	locations = (&igApiLocationsResponse{LocationList: []*Location{{Id: "2"}}}).locations(city)
+	if len(locations) != 1 || locations[0].City != city || locations[0].Country != city.Country {
+		t.Fatalf("unexpected locations %+v", locations)
+	}
+}
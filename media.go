@@ -0,0 +1,236 @@
+package iglocparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ansel1/merry"
+	"net/url"
+	"time"
+)
+
+type Media struct {
+	Id           string    `json:"id"`
+	Shortcode    string    `json:"shortcode"`
+	TakenAt      time.Time `json:"taken_at"`
+	Caption      string    `json:"caption,omitempty"`
+	LikeCount    int       `json:"like_count"`
+	CommentCount int       `json:"comment_count"`
+	OwnerId      string    `json:"owner_id,omitempty"`
+	ThumbnailUrl string    `json:"thumbnail_url,omitempty"`
+	DisplayUrl   string    `json:"display_url,omitempty"`
+	IsVideo      bool      `json:"is_video"`
+}
+
+func (self *Media) String() string {
+	return fmt.Sprintf("[id=%v; shortcode=%v; taken_at=%v]", self.Id, self.Shortcode, self.TakenAt)
+}
+
+type igGraphCount struct {
+	Count int `json:"count"`
+}
+
+type igGraphPageInfo struct {
+	HasNextPage bool   `json:"has_next_page"`
+	EndCursor   string `json:"end_cursor"`
+}
+
+type igGraphMediaNode struct {
+	Id                 string `json:"id"`
+	Shortcode          string `json:"shortcode"`
+	TakenAtTimestamp   int64  `json:"taken_at_timestamp"`
+	EdgeMediaToCaption struct {
+		Edges []struct {
+			Node struct {
+				Text string `json:"text"`
+			} `json:"node"`
+		} `json:"edges"`
+	} `json:"edge_media_to_caption"`
+	EdgeLikedBy          *igGraphCount `json:"edge_liked_by"`
+	EdgeMediaPreviewLike *igGraphCount `json:"edge_media_preview_like"`
+	EdgeMediaToComment   *igGraphCount `json:"edge_media_to_comment"`
+	Owner                struct {
+		Id string `json:"id"`
+	} `json:"owner"`
+	ThumbnailSrc string `json:"thumbnail_src"`
+	DisplayUrl   string `json:"display_url"`
+	IsVideo      bool   `json:"is_video"`
+}
+
+func (self *igGraphMediaNode) media() *Media {
+	media := &Media{
+		Id:           self.Id,
+		Shortcode:    self.Shortcode,
+		OwnerId:      self.Owner.Id,
+		ThumbnailUrl: self.ThumbnailSrc,
+		DisplayUrl:   self.DisplayUrl,
+		IsVideo:      self.IsVideo,
+	}
+
+	if self.TakenAtTimestamp > 0 {
+		media.TakenAt = time.Unix(self.TakenAtTimestamp, 0).UTC()
+	}
+
+	if len(self.EdgeMediaToCaption.Edges) > 0 {
+		media.Caption = self.EdgeMediaToCaption.Edges[0].Node.Text
+	}
+
+	if self.EdgeLikedBy != nil {
+		media.LikeCount = self.EdgeLikedBy.Count
+	} else if self.EdgeMediaPreviewLike != nil {
+		media.LikeCount = self.EdgeMediaPreviewLike.Count
+	}
+
+	if self.EdgeMediaToComment != nil {
+		media.CommentCount = self.EdgeMediaToComment.Count
+	}
+
+	return media
+}
+
+type igGraphMediaConnection struct {
+	Count    int             `json:"count"`
+	PageInfo igGraphPageInfo `json:"page_info"`
+	Edges    []struct {
+		Node *igGraphMediaNode `json:"node"`
+	} `json:"edges"`
+}
+
+func (self *igGraphMediaConnection) media() []*Media {
+	if self == nil {
+		return nil
+	}
+
+	var list []*Media
+	for _, edge := range self.Edges {
+		if edge.Node == nil {
+			continue
+		}
+
+		list = append(list, edge.Node.media())
+	}
+
+	return list
+}
+
+var IgLocationMediaQueryHash = "1b84447a4d8b6d6d0426fefb34514485"
+var DefaultLocationMediaPageSize = 12
+
+const IgGraphqlQueryPath = "graphql/query"
+
+type LocationMediaCursorState struct {
+	LocationId  string `json:"location_id"`
+	EndCursor   string `json:"end_cursor,omitempty"`
+	HasNextPage bool   `json:"has_next_page"`
+}
+
+type igGraphLocationMediaResponse struct {
+	Data struct {
+		Location *struct {
+			EdgeLocationToMedia *igGraphMediaConnection `json:"edge_location_to_media"`
+		} `json:"location"`
+	} `json:"data"`
+	Status string `json:"status,omitempty"`
+}
+
+type LocationMediaCursor struct {
+	locationId  string
+	endCursor   string
+	hasNextPage bool
+
+	PageSize int
+}
+
+func (self *LocationMediaCursor) Has() bool {
+	return self.hasNextPage
+}
+
+func (self *LocationMediaCursor) State() *LocationMediaCursorState {
+	return &LocationMediaCursorState{
+		LocationId:  self.locationId,
+		EndCursor:   self.endCursor,
+		HasNextPage: self.hasNextPage,
+	}
+}
+
+func (self *LocationMediaCursor) Next(client *IgApiClient) ([]*Media, error) {
+	pageSize := self.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultLocationMediaPageSize
+	}
+
+	variables := map[string]interface{}{
+		"id":    self.locationId,
+		"first": pageSize,
+	}
+	if self.endCursor != "" {
+		variables["after"] = self.endCursor
+	}
+
+	rawVariables, err := json.Marshal(variables)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	query := url.Values{}
+	query.Set("query_hash", IgLocationMediaQueryHash)
+	query.Set("variables", string(rawVariables))
+
+	link := GetIgLinkWithLeadingSlash(IgGraphqlQueryPath) + "?" + query.Encode()
+	referrer := GetIgLinkWithLeadingSlash(IgExploreLocationsPath, self.locationId)
+
+	body, err := client.doGet(link, referrer)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	res := &igGraphLocationMediaResponse{}
+	if err := json.Unmarshal(body, res); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	if res.Status != "ok" || res.Data.Location == nil || res.Data.Location.EdgeLocationToMedia == nil {
+		return nil, merry.WithUserMessage(ErrInvalidIgApiResponseCode, string(body))
+	}
+
+	edge := res.Data.Location.EdgeLocationToMedia
+	self.endCursor = edge.PageInfo.EndCursor
+	self.hasNextPage = edge.PageInfo.HasNextPage && edge.PageInfo.EndCursor != ""
+
+	return edge.media(), nil
+}
+
+func GetLocationMediaCursor(locationId string) *LocationMediaCursor {
+	return &LocationMediaCursor{
+		locationId:  locationId,
+		hasNextPage: true,
+	}
+}
+
+func NewLocationMediaCursorFromState(state *LocationMediaCursorState) *LocationMediaCursor {
+	return &LocationMediaCursor{
+		locationId:  state.LocationId,
+		endCursor:   state.EndCursor,
+		hasNextPage: state.HasNextPage,
+	}
+}
+
+func ParseAllLocationMedia(client *IgApiClient, locationId string, callback func(state *LocationMediaCursorState, media []*Media)) ([]*Media, error) {
+	var media []*Media
+
+	cursor := GetLocationMediaCursor(locationId)
+
+	for cursor.Has() {
+		list, err := cursor.Next(client)
+		if err != nil {
+			return nil, merry.Wrap(err)
+		}
+
+		if callback != nil {
+			callback(cursor.State(), list)
+		}
+
+		media = append(media, list...)
+	}
+
+	return media, nil
+}
@@ -0,0 +1,114 @@
+package iglocparser
+
+import (
+	"encoding/json"
+	"github.com/ansel1/merry"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+const IgLocationSearchPath = "location_search"
+
+const IgExternalIdSourceFacebookPlaces = "facebook_places"
+
+const earthRadiusMeters = 6371008.8
+
+type igLocationSearchVenue struct {
+	Pk               json.Number `json:"pk"`
+	ExternalId       json.Number `json:"external_id"`
+	ExternalIdSource string      `json:"external_id_source"`
+	Name             string      `json:"name"`
+	Address          string      `json:"address"`
+	Lat              float64     `json:"lat"`
+	Lng              float64     `json:"lng"`
+}
+
+type igLocationSearchResponse struct {
+	Venues []*igLocationSearchVenue `json:"venues"`
+	Status string                   `json:"status"`
+}
+
+// location_search venues are usually keyed by their Facebook place id, which is not an
+// Instagram location id. Such candidates keep it in FacebookPlacesId and leave Id empty.
+func (self *igLocationSearchVenue) candidate(latitude, longitude float64) *LocationCandidate {
+	c := &LocationCandidate{
+		Location: &Location{
+			Id:   self.Pk.String(),
+			Name: self.Name,
+		},
+		Latitude:  self.Lat,
+		Longitude: self.Lng,
+		Address:   self.Address,
+		Distance:  DistanceMeters(latitude, longitude, self.Lat, self.Lng),
+	}
+
+	if self.ExternalIdSource == IgExternalIdSourceFacebookPlaces {
+		c.FacebookPlacesId = self.ExternalId.String()
+	}
+
+	if c.Id == "" && c.FacebookPlacesId == "" {
+		return nil
+	}
+
+	return c
+}
+
+func (self *igLocationSearchResponse) candidates(latitude, longitude float64) []*LocationCandidate {
+	var candidates []*LocationCandidate
+	for _, v := range self.Venues {
+		if v == nil {
+			continue
+		}
+
+		if c := v.candidate(latitude, longitude); c != nil {
+			candidates = append(candidates, c)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Distance < candidates[j].Distance
+	})
+
+	return candidates
+}
+
+func SearchNearbyLocations(client IgRequester, latitude, longitude float64, query string) ([]*LocationCandidate, error) {
+	params := url.Values{}
+	params.Set("latitude", strconv.FormatFloat(latitude, 'f', -1, 64))
+	params.Set("longitude", strconv.FormatFloat(longitude, 'f', -1, 64))
+	if query != "" {
+		params.Set("search_query", query)
+	}
+
+	body, err := getIgJson(client, GetIgLinkWithLeadingSlash(IgLocationSearchPath)+"?"+params.Encode(), GetIgLinkWithLeadingSlash(IgExploreLocationsPath))
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	res := &igLocationSearchResponse{}
+	if err := json.Unmarshal(body, res); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	if res.Status != "ok" {
+		return nil, merry.WithUserMessage(ErrInvalidIgApiResponseCode, string(body))
+	}
+
+	return res.candidates(latitude, longitude), nil
+}
+
+func (self *IgApiClient) SearchNearbyLocations(latitude, longitude float64, query string) ([]*LocationCandidate, error) {
+	return SearchNearbyLocations(self.client, latitude, longitude, query)
+}
+
+func DistanceMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lng2 - lng1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusMeters * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,36 @@
+package iglocparser
+
+import (
+	"net/http"
+	"testing"
+)
+
+const testLocationSearchJson = `{"status":"ok","venues":[` +
+	`{"external_id":"106078429431815","external_id_source":"facebook_places","name":"Far","address":"","lat":55.8,"lng":37.7},` +
+	`{"pk":"213385402","external_id":"110589025627484","external_id_source":"facebook_places","name":"Near","address":"Main 1","lat":55.75,"lng":37.61},` +
+	`{"external_id":"42","external_id_source":"unknown","name":"Unknown","lat":55.75,"lng":37.61},` +
+	`null]}`
+
+func TestSearchNearbyLocationsIds(t *testing.T) {
+	client, _ := newTestServerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testLocationSearchJson))
+	})
+
+	candidates, err := SearchNearbyLocations(client, 55.75, 37.61, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(candidates) != 2 {
+		t.Fatalf("got %d candidates, want 2", len(candidates))
+	}
+
+	near, far := candidates[0], candidates[1]
+	if near.Name != "Near" || near.Id != "213385402" || near.FacebookPlacesId != "110589025627484" {
+		t.Errorf("near candidate: id %q, facebook places id %q, name %q", near.Id, near.FacebookPlacesId, near.Name)
+	}
+
+	if far.Name != "Far" || far.Id != "" || far.FacebookPlacesId != "106078429431815" {
+		t.Errorf("far candidate: id %q, facebook places id %q, name %q", far.Id, far.FacebookPlacesId, far.Name)
+	}
+}
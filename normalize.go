@@ -0,0 +1,155 @@
+package iglocparser
+
+import (
+	"github.com/nyaruka/phonenumbers"
+	"golang.org/x/text/unicode/norm"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+type PlaceNormalized struct {
+	Name          string `json:"name,omitempty"`
+	Phone         string `json:"phone,omitempty"`
+	Website       string `json:"website,omitempty"`
+	StreetAddress string `json:"street_address,omitempty"`
+	ZipCode       string `json:"zip_code,omitempty"`
+	CityName      string `json:"city_name,omitempty"`
+	RegionName    string `json:"region_name,omitempty"`
+	CountryCode   string `json:"country_code,omitempty"`
+}
+
+func (self *Place) countryCode() string {
+	countryCode := NormalizeCountryCode(self.Address.CountryCode)
+	if countryCode == "" && self.Country != nil {
+		countryCode = NormalizeCountryCode(self.Country.Id)
+	}
+
+	return countryCode
+}
+
+func (self *Place) Normalize() *PlaceNormalized {
+	countryCode := self.countryCode()
+
+	self.Normalized = &PlaceNormalized{
+		Name:          NormalizeText(self.Name),
+		Phone:         NormalizePhone(self.Phone, countryCode),
+		Website:       NormalizeWebsite(self.Website),
+		StreetAddress: NormalizeText(self.Address.StreetAddress),
+		ZipCode:       strings.ToUpper(NormalizeText(self.Address.ZipCode)),
+		CityName:      NormalizeText(self.Address.CityName),
+		RegionName:    NormalizeText(self.Address.RegionName),
+		CountryCode:   countryCode,
+	}
+
+	return self.Normalized
+}
+
+func NormalizeText(s string) string {
+	return strings.Join(strings.FieldsFunc(norm.NFC.String(s), unicode.IsSpace), " ")
+}
+
+// Officially assigned ISO 3166-1 alpha-2 codes, grouped by first letter.
+var iso3166Alpha2Codes = makeStringSet(strings.Fields(`
+	AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ
+	BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BV BW BY BZ
+	CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ
+	DE DJ DK DM DO DZ
+	EC EE EG EH ER ES ET
+	FI FJ FK FM FO FR
+	GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY
+	HK HM HN HR HT HU
+	ID IE IL IM IN IO IQ IR IS IT
+	JE JM JO JP
+	KE KG KH KI KM KN KP KR KW KY KZ
+	LA LB LC LI LK LR LS LT LU LV LY
+	MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ
+	NA NC NE NF NG NI NL NO NP NR NU NZ
+	OM
+	PA PE PF PG PH PK PL PM PN PR PS PT PW PY
+	QA
+	RE RO RS RU RW
+	SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ
+	TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ
+	UA UG UM US UY UZ
+	VA VC VE VG VI VN VU
+	WF WS
+	YE YT
+	ZA ZM ZW
+`))
+
+func makeStringSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+
+	return set
+}
+
+func NormalizeCountryCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := iso3166Alpha2Codes[code]; !ok {
+		return ""
+	}
+
+	return code
+}
+
+func NormalizePhone(phone string, countryCode string) string {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return ""
+	}
+
+	region := countryCode
+	if region == "" {
+		region = "ZZ"
+	}
+
+	number, err := phonenumbers.Parse(phone, region)
+	if err != nil || !phonenumbers.IsValidNumber(number) {
+		return ""
+	}
+
+	return phonenumbers.Format(number, phonenumbers.E164)
+}
+
+func NormalizeWebsite(website string) string {
+	website = strings.TrimSpace(website)
+	if website == "" {
+		return ""
+	}
+
+	if !strings.Contains(website, "://") {
+		website = "http://" + website
+	}
+
+	u, err := url.Parse(website)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	u.Host = host
+	if port != "" {
+		u.Host = host + ":" + port
+	}
+
+	if u.Path == "/" {
+		u.Path = ""
+	}
+	u.Fragment = ""
+
+	return u.String()
+}
@@ -0,0 +1,23 @@
+package iglocparser
+
+import (
+	"testing"
+)
+
+func TestNormalizeCountryCode(t *testing.T) {
+	for _, code := range []string{"RU", "us", " gb ", "AQ", "BV", "GS", "HM", "UM"} {
+		if NormalizeCountryCode(code) == "" {
+			t.Errorf("%q rejected, want accepted", code)
+		}
+	}
+
+	for _, code := range []string{"", "R", "RUS", "AC", "TA", "XK", "ZZ", "EU", "UK"} {
+		if got := NormalizeCountryCode(code); got != "" {
+			t.Errorf("%q normalized to %q, want rejected", code, got)
+		}
+	}
+
+	if len(iso3166Alpha2Codes) != 249 {
+		t.Errorf("got %d codes, want 249", len(iso3166Alpha2Codes))
+	}
+}
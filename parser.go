@@ -211,12 +211,42 @@ func (self *IgApiClient) post(link string, data url.Values, referrer string) (*h
 	return res, nil
 }
 
+func (self *IgApiClient) get(link string, referrer string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	self.client.SetHeaders(req.Header, referrer)
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+
+	res, err := self.client.Do(req)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return res, nil
+}
+
+func (self *IgApiClient) doGet(link string, referrer string) ([]byte, error) {
+	resp, err := self.get(link, referrer)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return readIgApiResponse(resp)
+}
+
 func (self *IgApiClient) do(link string, page int, referrer string) ([]byte, error) {
 	resp, err := self.request(link, page, referrer)
 	if err != nil {
 		return nil, merry.Wrap(err)
 	}
 
+	return readIgApiResponse(resp)
+}
+
+func readIgApiResponse(resp *http.Response) ([]byte, error) {
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		return nil, merry.WithHTTPCode(ErrInvalidResponseStatus, resp.StatusCode)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/ansel1/merry"
 	"github.com/buger/jsonparser"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"regexp"
@@ -28,16 +29,232 @@ type Place struct {
 	Phone            string  `json:"phone,omitempty"`
 	PrimaryAliasOnFb string  `json:"primary_alias_on_fb,omitempty"`
 	ProfilePicUrl    string  `json:"profile_pic_url,omitempty"`
+	HasPublicPage    bool    `json:"has_public_page,omitempty"`
+	Category         string  `json:"category,omitempty"`
+	MediaCount       int     `json:"media_count,omitempty"`
 
 	Address PlaceAddress
 
 	Country *Country `json:"country"`
 	City    *City    `json:"city"`
+
+	TopPosts          []*Media                  `json:"top_posts,omitempty"`
+	RecentPosts       []*Media                  `json:"recent_posts,omitempty"`
+	RecentPostsCursor *LocationMediaCursorState `json:"recent_posts_cursor,omitempty"`
+
+	Raw json.RawMessage `json:"raw,omitempty"`
+
+	Normalized *PlaceNormalized `json:"normalized,omitempty"`
+	Contacts   *PlaceContacts   `json:"contacts,omitempty"`
+}
+
+func (self *Place) String() string {
+	return getEntityString(self)
+}
+
+func (self *Place) URL() string {
+	return GetIgLinkWithLeadingSlash(IgExploreLocationsPath, self.Id, self.Slug)
+}
+
+var DefaultPlaceMaxBodySize int64 = 8 << 20
+
+type ParsePlaceOptions struct {
+	Strategies []PlaceParseStrategy
+	KeepRaw    bool
+	Normalize  bool
+	Stream     bool
+
+	// MaxBodySize caps response bodies, 0 means DefaultPlaceMaxBodySize and a negative value disables the cap.
+	MaxBodySize int64
+}
+
+func (self *ParsePlaceOptions) maxBodySize() int64 {
+	if self.MaxBodySize == 0 {
+		return DefaultPlaceMaxBodySize
+	}
+
+	return self.MaxBodySize
+}
+
+type PlaceParseStrategy int
+
+const (
+	PlaceStrategyNone PlaceParseStrategy = iota
+	PlaceStrategySharedData
+	PlaceStrategyAdditionalData
+	PlaceStrategyJson
+)
+
+var DefaultPlaceParseStrategies = []PlaceParseStrategy{
+	PlaceStrategySharedData,
+	PlaceStrategyAdditionalData,
+	PlaceStrategyJson,
+}
+
+func (self PlaceParseStrategy) String() string {
+	switch self {
+	case PlaceStrategyNone:
+		return "none"
+	case PlaceStrategySharedData:
+		return "shared_data"
+	case PlaceStrategyAdditionalData:
+		return "additional_data"
+	case PlaceStrategyJson:
+		return "json"
+	}
+
+	return "unknown"
+}
+
+func (self PlaceParseStrategy) isPage() bool {
+	return self == PlaceStrategySharedData || self == PlaceStrategyAdditionalData
+}
+
+func (self PlaceParseStrategy) parse(body []byte) (*Place, error) {
+	switch self {
+	case PlaceStrategySharedData:
+		return getPlaceInfoFromPageBody(body)
+	case PlaceStrategyAdditionalData:
+		return getPlaceInfoFromAdditionalData(body)
+	case PlaceStrategyJson:
+		return getPlaceInfoFromJsonBody(body)
+	}
+
+	return nil, merry.Errorf("unknown place parse strategy %d", self)
 }
 
 func ParsePlace(client *Client, id string, referrer string) (*Place, error) {
-	link := GetIgLinkWithLeadingSlash(IgExploreLocationsPath, id)
+	place, _, err := ParsePlaceWithStrategies(client, id, referrer, DefaultPlaceParseStrategies...)
+	return place, err
+}
+
+func ParsePlaceWithStrategies(client *Client, id string, referrer string, strategies ...PlaceParseStrategy) (*Place, PlaceParseStrategy, error) {
+	return ParsePlaceWithOptions(client, id, referrer, &ParsePlaceOptions{Strategies: strategies})
+}
+
+func ParsePlaceWithOptions(client *Client, id string, referrer string, opts *ParsePlaceOptions) (*Place, PlaceParseStrategy, error) {
+	if opts == nil {
+		opts = &ParsePlaceOptions{}
+	}
+
+	strategies := opts.Strategies
+	if len(strategies) == 0 {
+		strategies = DefaultPlaceParseStrategies
+	}
+
+	// each body is fetched at most once per call, failed fetches included
+	var pageBody, jsonBody []byte
+	var pageErr, jsonErr error
+	var isPageFetched, isJsonFetched bool
+	var lastErr error
+	for _, strategy := range strategies {
+		if opts.Stream && strategy == PlaceStrategySharedData && !isPageFetched {
+			stream, err := openPlaceBody(client, GetIgLinkWithLeadingSlash(IgExploreLocationsPath, id), referrer)
+			if err == nil {
+				place, err := ParsePlaceFromSharedDataStream(stream, opts)
+				stream.Close()
+				if err == nil {
+					return place, strategy, nil
+				}
+
+				// the stream is consumed, so later page strategies fetch the page again
+				lastErr = err
+				continue
+			}
+
+			pageErr = err
+			isPageFetched = true
+		}
+
+		var body []byte
+		var err error
+		if strategy.isPage() {
+			if !isPageFetched {
+				pageBody, pageErr = fetchPlaceBody(client, GetIgLinkWithLeadingSlash(IgExploreLocationsPath, id), referrer, opts.maxBodySize())
+				isPageFetched = true
+			}
+			body, err = pageBody, pageErr
+		} else {
+			if !isJsonFetched {
+				jsonBody, jsonErr = fetchPlaceBody(client, GetIgLinkWithLeadingSlash(IgExploreLocationsPath, id)+"?__a=1", referrer, opts.maxBodySize())
+				isJsonFetched = true
+			}
+			body, err = jsonBody, jsonErr
+		}
+
+		if err != nil {
+			if merry.Is(err, ErrUndefinedLocation) {
+				return nil, PlaceStrategyNone, err
+			}
+
+			lastErr = err
+			continue
+		}
+
+		place, err := strategy.parse(body)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		opts.apply(place)
+		return place, strategy, nil
+	}
+
+	if lastErr == nil {
+		lastErr = merry.New("no place parse strategies succeeded")
+	}
+
+	return nil, PlaceStrategyNone, merry.Wrap(lastErr)
+}
+
+func (self *ParsePlaceOptions) apply(place *Place) {
+	if self.KeepRaw {
+		place.Raw = append(json.RawMessage(nil), place.Raw...)
+	} else {
+		place.Raw = nil
+	}
+
+	if self.Normalize {
+		place.Normalize()
+	}
+}
+
+func ParsePlaceFromReader(r io.Reader, opts *ParsePlaceOptions) (*Place, PlaceParseStrategy, error) {
+	if opts == nil {
+		opts = &ParsePlaceOptions{}
+	}
+
+	strategies := opts.Strategies
+	if len(strategies) == 0 {
+		strategies = DefaultPlaceParseStrategies
+	}
+
+	body, err := ioutil.ReadAll(newMaxSizeReader(r, opts.maxBodySize()))
+	if err != nil {
+		return nil, PlaceStrategyNone, merry.Wrap(err)
+	}
+
+	var lastErr error
+	for _, strategy := range strategies {
+		place, err := strategy.parse(body)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		opts.apply(place)
+		return place, strategy, nil
+	}
+
+	if lastErr == nil {
+		lastErr = merry.New("no place parse strategies succeeded")
+	}
+
+	return nil, PlaceStrategyNone, merry.Wrap(lastErr)
+}
 
+func openPlaceBody(client *Client, link string, referrer string) (io.ReadCloser, error) {
 	req, err := http.NewRequest(http.MethodGet, link, nil)
 	if err != nil {
 		return nil, merry.Wrap(err)
@@ -49,24 +266,30 @@ func ParsePlace(client *Client, id string, referrer string) (*Place, error) {
 		return nil, merry.Wrap(err)
 	}
 
-	defer res.Body.Close()
 	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
 		return nil, merry.Wrap(ErrUndefinedLocation)
 	} else if res.StatusCode != http.StatusOK {
+		res.Body.Close()
 		return nil, merry.WithHTTPCode(ErrInvalidResponseStatus, res.StatusCode)
 	}
 
-	body, err := ioutil.ReadAll(res.Body)
+	return res.Body, nil
+}
+
+func fetchPlaceBody(client *Client, link string, referrer string, maxSize int64) ([]byte, error) {
+	body, err := openPlaceBody(client, link, referrer)
 	if err != nil {
 		return nil, merry.Wrap(err)
 	}
+	defer body.Close()
 
-	place, err := getPlaceInfoFromPageBody(body)
+	data, err := ioutil.ReadAll(newMaxSizeReader(body, maxSize))
 	if err != nil {
 		return nil, merry.Wrap(err)
 	}
 
-	return place, nil
+	return data, nil
 }
 
 var regexPlaceJsonSharedDataFinder = regexp.MustCompile(`<script type="text/javascript">window\._sharedData = (.*);</script>`)
@@ -78,6 +301,8 @@ type placeLocationJsonResponse struct {
 		Country *Country `json:"country,omitempty"`
 		City    *City    `json:"city,omitempty"`
 	} `json:"directory,omitempty"`
+	EdgeLocationToTopPosts *igGraphMediaConnection `json:"edge_location_to_top_posts,omitempty"`
+	EdgeLocationToMedia    *igGraphMediaConnection `json:"edge_location_to_media,omitempty"`
 }
 
 func getPlaceInfoFromPageBody(body []byte) (*Place, error) {
@@ -109,16 +334,49 @@ func getPlaceInfoFromPageBody(body []byte) (*Place, error) {
 		return nil, merry.New("missing LocationsPage from sharedData json")
 	}
 
-	jsonLocationData, _, _, err := jsonparser.Get(firstValue, "graphql", "location")
+	return getPlaceInfoFromGraphql(firstValue)
+}
+
+var regexPlaceAdditionalDataFinder = regexp.MustCompile(`window\.__additionalDataLoaded\(\s*'[^']*'\s*,\s*(\{.*?\})\s*\);\s*</script>`)
+
+func getPlaceInfoFromAdditionalData(body []byte) (*Place, error) {
+	matches := regexPlaceAdditionalDataFinder.FindSubmatch(body)
+	if len(matches) < 2 {
+		return nil, merry.New("failed to find __additionalDataLoaded json in body")
+	}
+
+	return getPlaceInfoFromGraphql(matches[1])
+}
+
+func getPlaceInfoFromJsonBody(body []byte) (*Place, error) {
+	return getPlaceInfoFromGraphql(body)
+}
+
+func getPlaceInfoFromGraphql(data []byte) (*Place, error) {
+	jsonLocationData, _, _, err := jsonparser.Get(data, "graphql", "location")
 	if err != nil {
 		return nil, merry.Wrap(err)
 	}
 
-	res := placeLocationJsonResponse{}
-	if err := json.Unmarshal(jsonLocationData, &res); err != nil {
+	return getPlaceInfoFromLocationJson(jsonLocationData)
+}
+
+func getPlaceInfoFromLocationJson(jsonLocationData []byte) (*Place, error) {
+	res := &placeLocationJsonResponse{}
+	if err := json.Unmarshal(jsonLocationData, res); err != nil {
 		return nil, merry.Wrap(err)
 	}
 
+	place, err := res.place()
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	place.Raw = jsonLocationData
+	return place, nil
+}
+
+func (res *placeLocationJsonResponse) place() (*Place, error) {
 	addr := PlaceAddress{}
 	if res.AddressJson != "" {
 		if err := json.Unmarshal([]byte(res.AddressJson), &addr); err != nil {
@@ -128,6 +386,18 @@ func getPlaceInfoFromPageBody(body []byte) (*Place, error) {
 
 	place := &res.Place
 	place.Address = addr
+	if res.EdgeLocationToMedia != nil {
+		place.MediaCount = res.EdgeLocationToMedia.Count
+	}
+	place.TopPosts = res.EdgeLocationToTopPosts.media()
+	place.RecentPosts = res.EdgeLocationToMedia.media()
+	if res.EdgeLocationToMedia != nil && res.EdgeLocationToMedia.PageInfo.HasNextPage {
+		place.RecentPostsCursor = &LocationMediaCursorState{
+			LocationId:  place.Id,
+			EndCursor:   res.EdgeLocationToMedia.PageInfo.EndCursor,
+			HasNextPage: true,
+		}
+	}
 	if res.Directory != nil {
 		if res.Directory.Country != nil {
 			place.Country = res.Directory.Country
@@ -135,6 +405,14 @@ func getPlaceInfoFromPageBody(body []byte) (*Place, error) {
 
 		if res.Directory.City != nil {
 			place.City = res.Directory.City
+			if place.City.Country == nil {
+				place.City.Country = place.Country
+			}
+		}
+	}
+	if place.Blurb != "" {
+		if contacts := ExtractPlaceContacts(place.Blurb, place.countryCode()); !contacts.IsEmpty() {
+			place.Contacts = contacts
 		}
 	}
 
@@ -0,0 +1,150 @@
+package iglocparser
+
+import (
+	"github.com/ansel1/merry"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var DefaultParsePlacesAttempts = 3
+
+type ParsePlacesOptions struct {
+	Concurrency       int
+	Attempts          int
+	RateLimitCooldown time.Duration
+	Referrer          string
+	Place             *ParsePlaceOptions
+}
+
+type PlaceResult struct {
+	Id       string
+	Place    *Place
+	Strategy PlaceParseStrategy
+	NotFound bool
+	Err      error
+}
+
+func StreamLocationIds(locations <-chan *Location) <-chan string {
+	ids := make(chan string)
+	go func() {
+		defer close(ids)
+		for location := range locations {
+			ids <- location.Id
+		}
+	}()
+
+	return ids
+}
+
+func ParsePlaces(clients []*Client, ids <-chan string, opts *ParsePlacesOptions) <-chan *PlaceResult {
+	if opts == nil {
+		opts = &ParsePlacesOptions{}
+	}
+
+	attempts := opts.Attempts
+	if attempts <= 0 {
+		attempts = DefaultParsePlacesAttempts
+	}
+
+	// cooldowns are per proxy, so each client is added once and shared by up to parallelism
+	// requests, while MaxRunning keeps the total at concurrency and every client in rotation
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = len(clients)
+	}
+
+	parallelism := 1
+	if len(clients) > 0 {
+		parallelism = (concurrency + len(clients) - 1) / len(clients)
+	}
+
+	results := make(chan *PlaceResult, concurrency)
+
+	var mu sync.Mutex
+	isClosed := false
+	send := func(result *PlaceResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !isClosed {
+			results <- result
+		}
+	}
+
+	parser, _ := NewOpenEffortParserWithOptions(clients, func(client *EffortParserClient, task *EffortParserTask) bool {
+		id := task.Data.(string)
+
+		place, strategy, err := ParsePlaceWithOptions(client.Client, id, opts.Referrer, opts.Place)
+		if err == nil {
+			send(&PlaceResult{Id: id, Place: place, Strategy: strategy})
+			return true
+		}
+
+		if merry.Is(err, ErrUndefinedLocation) {
+			send(&PlaceResult{Id: id, NotFound: true, Err: err})
+			return true
+		}
+
+		task.AddError(err)
+		if opts.RateLimitCooldown > 0 && merry.HTTPCode(err) == http.StatusTooManyRequests {
+			client.Cooldown(opts.RateLimitCooldown)
+		}
+
+		if task.AttemptsDecrease() <= 0 {
+			task.Fail(err)
+			send(&PlaceResult{Id: id, Err: err})
+			return true
+		}
+
+		return false
+	}, &EffortParserOptions{
+		ClientParallelism: parallelism,
+		MaxRunning:        concurrency,
+	})
+
+	go func() {
+		for id := range ids {
+			parser.AddTask(id, attempts)
+		}
+		parser.CloseTasks()
+	}()
+
+	go func() {
+		var err error
+		if len(clients) == 0 {
+			err = ErrClientsExceed
+		} else {
+			err = parser.Run()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			results <- &PlaceResult{Err: err}
+		}
+
+		isClosed = true
+		close(results)
+	}()
+
+	return results
+}
+
+func ParsePlaceIds(clients []*Client, ids []string, opts *ParsePlacesOptions) []*PlaceResult {
+	stream := make(chan string)
+	go func() {
+		defer close(stream)
+		for _, id := range ids {
+			stream <- id
+		}
+	}()
+
+	var results []*PlaceResult
+	for result := range ParsePlaces(clients, stream, opts) {
+		results = append(results, result)
+	}
+
+	return results
+}
@@ -0,0 +1,203 @@
+package iglocparser
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParsePlacesClientParallelism(t *testing.T) {
+	var mu sync.Mutex
+	inflight, maxInflight := 0, 0
+	client, _ := newTestServerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inflight++
+		if inflight > maxInflight {
+			maxInflight = inflight
+		}
+		mu.Unlock()
+
+		time.Sleep(30 * time.Millisecond)
+
+		mu.Lock()
+		inflight--
+		mu.Unlock()
+
+		w.Write([]byte(testSharedDataPage(testLocationJson)))
+	})
+
+	var ids []string
+	for i := 0; i < 9; i++ {
+		ids = append(ids, strconv.Itoa(i))
+	}
+
+	results := ParsePlaceIds([]*Client{client}, ids, &ParsePlacesOptions{Concurrency: 3})
+	if len(results) != len(ids) {
+		t.Fatalf("expected %d results, got %d", len(ids), len(results))
+	}
+
+	for _, result := range results {
+		if result.Err != nil || result.Strategy != PlaceStrategySharedData {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if maxInflight != 3 {
+		t.Fatalf("expected 3 parallel requests on one client, got %d", maxInflight)
+	}
+}
+
+func TestParsePlacesCooldownBenchesSharedClient(t *testing.T) {
+	const cooldown = 200 * time.Millisecond
+
+	var mu sync.Mutex
+	var rateLimitedAt time.Time
+	var started []time.Time
+	client, _ := newTestServerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		started = append(started, time.Now())
+		isFirst := rateLimitedAt.IsZero()
+		if isFirst {
+			rateLimitedAt = time.Now()
+		}
+		mu.Unlock()
+
+		if isFirst {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(testSharedDataPage(testLocationJson)))
+	})
+
+	ids := []string{"1", "2", "3", "4", "5", "6"}
+	results := ParsePlaceIds([]*Client{client}, ids, &ParsePlacesOptions{
+		Concurrency:       3,
+		RateLimitCooldown: cooldown,
+		Place:             &ParsePlaceOptions{Strategies: []PlaceParseStrategy{PlaceStrategySharedData}},
+	})
+
+	for _, result := range results {
+		if result.Err != nil {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// requests already in flight may land right after the 429, nothing new may start during the cooldown
+	windowStart := rateLimitedAt.Add(30 * time.Millisecond)
+	windowEnd := rateLimitedAt.Add(cooldown - 10*time.Millisecond)
+	for _, at := range started {
+		if at.After(windowStart) && at.Before(windowEnd) {
+			t.Fatalf("request started %s after the rate limit, during the cooldown", at.Sub(rateLimitedAt))
+		}
+	}
+}
+
+type testInflight struct {
+	mu          sync.Mutex
+	inflight    int
+	maxInflight int
+}
+
+func (self *testInflight) handler(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		self.mu.Lock()
+		self.inflight++
+		if self.inflight > self.maxInflight {
+			self.maxInflight = self.inflight
+		}
+		self.mu.Unlock()
+
+		time.Sleep(delay)
+
+		self.mu.Lock()
+		self.inflight--
+		self.mu.Unlock()
+
+		w.Write([]byte(testSharedDataPage(testLocationJson)))
+	}
+}
+
+func (self *testInflight) max() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.maxInflight
+}
+
+func TestParsePlacesConcurrencyAcrossClients(t *testing.T) {
+	inflight := &testInflight{}
+
+	var clients []*Client
+	for i := 0; i < 3; i++ {
+		client, _ := newTestServerClient(t, inflight.handler(30*time.Millisecond))
+		clients = append(clients, client)
+	}
+
+	var ids []string
+	for i := 0; i < 15; i++ {
+		ids = append(ids, strconv.Itoa(i))
+	}
+
+	results := ParsePlaceIds(clients, ids, &ParsePlacesOptions{Concurrency: 5})
+	if len(results) != len(ids) {
+		t.Fatalf("expected %d results, got %d", len(ids), len(results))
+	}
+
+	// 3 clients round 5 up to 2 requests per client, the total must still stay at 5
+	if n := inflight.max(); n != 5 {
+		t.Fatalf("expected 5 parallel requests, got %d", n)
+	}
+}
+
+func TestParsePlacesConcurrencyBelowClients(t *testing.T) {
+	inflight := &testInflight{}
+
+	var clients []*Client
+	var transports []*testRoundTripper
+	for i := 0; i < 3; i++ {
+		client, transport := newTestServerClient(t, inflight.handler(5*time.Millisecond))
+		clients = append(clients, client)
+		transports = append(transports, transport)
+	}
+
+	var ids []string
+	for i := 0; i < 6; i++ {
+		ids = append(ids, strconv.Itoa(i))
+	}
+
+	results := ParsePlaceIds(clients, ids, &ParsePlacesOptions{
+		Concurrency: 1,
+		Place:       &ParsePlaceOptions{Strategies: []PlaceParseStrategy{PlaceStrategySharedData}},
+	})
+	if len(results) != len(ids) {
+		t.Fatalf("expected %d results, got %d", len(ids), len(results))
+	}
+
+	if n := inflight.max(); n != 1 {
+		t.Fatalf("expected 1 request at a time, got %d", n)
+	}
+
+	// no client is dropped to match the concurrency, they all take turns
+	for i, transport := range transports {
+		transport.mu.Lock()
+		count := 0
+		for _, n := range transport.requests {
+			count += n
+		}
+		transport.mu.Unlock()
+
+		if count == 0 {
+			t.Fatalf("client %d was never used", i)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package iglocparser
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/ansel1/merry"
+	"io"
+)
+
+var ErrResponseTooLarge = errors.New("response body is too large")
+
+var placeSharedDataMarker = []byte("window._sharedData = ")
+
+type maxSizeReader struct {
+	r    io.Reader
+	left int64
+}
+
+func newMaxSizeReader(r io.Reader, maxSize int64) io.Reader {
+	if maxSize <= 0 {
+		return r
+	}
+
+	return &maxSizeReader{r: r, left: maxSize}
+}
+
+func (self *maxSizeReader) Read(p []byte) (int, error) {
+	if self.left <= 0 {
+		var b [1]byte
+		if n, _ := self.r.Read(b[:]); n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > self.left {
+		p = p[:self.left]
+	}
+
+	n, err := self.r.Read(p)
+	self.left -= int64(n)
+	return n, err
+}
+
+func ParsePlaceFromSharedDataStream(r io.Reader, opts *ParsePlaceOptions) (*Place, error) {
+	if opts == nil {
+		opts = &ParsePlaceOptions{}
+	}
+
+	br := bufio.NewReader(newMaxSizeReader(r, opts.maxBodySize()))
+	if err := skipUntil(br, placeSharedDataMarker); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	dec := json.NewDecoder(br)
+	if err := descendJsonPath(dec, "entry_data", "LocationsPage"); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	if err := expectJsonDelim(dec, '['); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	if !dec.More() {
+		return nil, merry.New("missing LocationsPage from sharedData json")
+	}
+
+	if err := expectJsonDelim(dec, '{'); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	if err := descendJsonObjectPath(dec, "graphql", "location"); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	var place *Place
+	var err error
+	if opts.KeepRaw {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, merry.Wrap(err)
+		}
+
+		if place, err = getPlaceInfoFromLocationJson(raw); err != nil {
+			return nil, merry.Wrap(err)
+		}
+	} else {
+		res := &placeLocationJsonResponse{}
+		if err := dec.Decode(res); err != nil {
+			return nil, merry.Wrap(err)
+		}
+
+		if place, err = res.place(); err != nil {
+			return nil, merry.Wrap(err)
+		}
+	}
+
+	if opts.Normalize {
+		place.Normalize()
+	}
+
+	return place, nil
+}
+
+func skipUntil(r *bufio.Reader, marker []byte) error {
+	matched := 0
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return merry.New("failed to find sharedData json in body")
+		} else if err != nil {
+			return err
+		}
+
+		for matched > 0 && b != marker[matched] {
+			matched = markerFallback(marker, matched)
+		}
+
+		if b == marker[matched] {
+			matched++
+		}
+
+		if matched == len(marker) {
+			return nil
+		}
+	}
+}
+
+func markerFallback(marker []byte, matched int) int {
+	for k := matched - 1; k > 0; k-- {
+		if string(marker[matched-k:matched]) == string(marker[:k]) {
+			return k
+		}
+	}
+
+	return 0
+}
+
+func descendJsonPath(dec *json.Decoder, keys ...string) error {
+	if err := expectJsonDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	return descendJsonObjectPath(dec, keys...)
+}
+
+// descendJsonObjectPath expects the decoder to be inside an object and leaves
+// it positioned right before the value of the last key.
+func descendJsonObjectPath(dec *json.Decoder, keys ...string) error {
+	for i, key := range keys {
+		if err := seekJsonKey(dec, key); err != nil {
+			return err
+		}
+
+		if i < len(keys)-1 {
+			if err := expectJsonDelim(dec, '{'); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func seekJsonKey(dec *json.Decoder, key string) error {
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if name, ok := token.(string); ok && name == key {
+			return nil
+		}
+
+		if err := skipJsonValue(dec); err != nil {
+			return err
+		}
+	}
+
+	return merry.Errorf("missing %v key in sharedData json", key)
+}
+
+func skipJsonValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func expectJsonDelim(dec *json.Decoder, expected json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != expected {
+		return merry.Errorf("unexpected json token %v, expected %v", token, expected)
+	}
+
+	return nil
+}
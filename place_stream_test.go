@@ -0,0 +1,129 @@
+package iglocparser
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ansel1/merry"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testLocationPage returns the saved location page from IGLOC_TEST_LOCATION_PAGE or, without it,
+// a page laid out like a saved one: a large head, sharedData with config and a full media feed, trailing scripts.
+func testLocationPage(tb testing.TB) []byte {
+	tb.Helper()
+
+	if path := os.Getenv("IGLOC_TEST_LOCATION_PAGE"); path != "" {
+		page, err := ioutil.ReadFile(path)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		return page
+	}
+
+	var edges []string
+	for i := 0; i < 150; i++ {
+		edges = append(edges, fmt.Sprintf(`{"node":{"id":"%d","shortcode":"B%09d","taken_at_timestamp":%d,`+
+			`"edge_media_to_caption":{"edges":[{"node":{"text":%q}}]},"edge_liked_by":{"count":%d},"edge_media_to_comment":{"count":%d},`+
+			`"owner":{"id":"%d"},"thumbnail_src":"https://scontent.cdninstagram.com/v/t51.2885-15/e35/%d_n.jpg?_nc_ht=scontent.cdninstagram.com&oh=%032d",`+
+			`"display_url":"https://scontent.cdninstagram.com/v/t51.2885-15/e35/%d_n.jpg","is_video":%v}}`,
+			2000000000000000000+i, i, 1500000000+i*3600, strings.Repeat("Coffee and views #cafe #moscow ", 12), i*7, i%13, 1000+i, i, i, i, i%5 == 0))
+	}
+
+	location := strings.Replace(testLocationJson, `"edges":[]},"edge_location_to_top_posts":{"count":0,"edges":[]}`,
+		`"edges":[`+strings.Join(edges, ",")+`]},"edge_location_to_top_posts":{"count":9,"edges":[`+strings.Join(edges[:9], ",")+`]}`, 1)
+
+	var page bytes.Buffer
+	page.WriteString(`<!DOCTYPE html><html lang="en" class="no-js not-logged-in client-root"><head><meta charset="utf-8">`)
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&page, `<link rel="preload" href="/static/bundles/es6/Consumer.js/%032x.js" as="script" type="text/javascript" crossorigin="anonymous" />`, i)
+	}
+	page.WriteString(`<style type="text/css">` + strings.Repeat(`.coreSpriteFacebookIcon{background-position:-246px -407px;width:16px;height:16px}`, 600) + `</style></head><body>`)
+	page.WriteString(`<script type="text/javascript">window._sharedData = {"config":{"csrf_token":"` + strings.Repeat("x", 32) + `","viewer":null},` +
+		`"country_code":"RU","language_code":"en","locale":"en_US","entry_data":{"LocationsPage":[{"logging_page_id":"locationPage_123","graphql":{"location":` +
+		location + `}}]},"hostname":"www.instagram.com","rollout_hash":"0a1b2c3d"};</script>`)
+	page.WriteString(`<script type="text/javascript">` + strings.Repeat(`(window.__bufferedErrors=window.__bufferedErrors||[]).push({});`, 2000) + `</script></body></html>`)
+
+	return page.Bytes()
+}
+
+func TestParsePlaceStreamMatchesBuffered(t *testing.T) {
+	page := testLocationPage(t)
+
+	buffered, _, err := ParsePlaceFromReader(bytes.NewReader(page), &ParsePlaceOptions{Strategies: []PlaceParseStrategy{PlaceStrategySharedData}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := ParsePlaceFromSharedDataStream(bytes.NewReader(page), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(buffered, stream) {
+		t.Fatalf("stream and buffered places differ:\n%+v\n%+v", buffered, stream)
+	}
+
+	if len(stream.RecentPosts) == 0 || stream.RecentPostsCursor == nil {
+		t.Fatalf("expected recent posts and a cursor, got %+v", stream)
+	}
+}
+
+func TestParsePlaceMaxBodySize(t *testing.T) {
+	page := testLocationPage(t)
+
+	defaultSize := DefaultPlaceMaxBodySize
+	defer func() {
+		DefaultPlaceMaxBodySize = defaultSize
+	}()
+	DefaultPlaceMaxBodySize = int64(len(page) / 2)
+
+	if _, _, err := ParsePlaceFromReader(bytes.NewReader(page), nil); !merry.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected the default cap to apply, got %v", err)
+	}
+
+	if _, err := ParsePlaceFromSharedDataStream(bytes.NewReader(page), nil); !merry.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected the default cap to apply to streams, got %v", err)
+	}
+
+	if _, _, err := ParsePlaceFromReader(bytes.NewReader(page), &ParsePlaceOptions{MaxBodySize: -1}); err != nil {
+		t.Fatalf("expected a negative size to disable the cap, got %v", err)
+	}
+
+	if _, _, err := ParsePlaceFromReader(bytes.NewReader(page), &ParsePlaceOptions{MaxBodySize: int64(len(page))}); err != nil {
+		t.Fatalf("expected a page of exactly the cap to pass, got %v", err)
+	}
+}
+
+func BenchmarkParsePlaceBuffered(b *testing.B) {
+	page := testLocationPage(b)
+	opts := &ParsePlaceOptions{Strategies: []PlaceParseStrategy{PlaceStrategySharedData}}
+
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ParsePlaceFromReader(bytes.NewReader(page), opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParsePlaceStream(b *testing.B) {
+	page := testLocationPage(b)
+
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ParsePlaceFromSharedDataStream(bytes.NewReader(page), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package iglocparser
+
+import (
+	"github.com/ansel1/merry"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testLocationJson = `{"id":"123","name":"Cafe","lat":55.75,"lng":37.61,"slug":"cafe","blurb":"","address_json":"{\"street_address\":\"Main 1\",\"zip_code\":\"101000\",\"city_name\":\"Moscow\",\"region_name\":\"\",\"country_code\":\"RU\"}","directory":{"country":{"id":"RU","name":"Russia","slug":"russia"},"city":{"id":"c102","name":"Moscow","slug":"moscow"}},"edge_location_to_media":{"count":10,"page_info":{"has_next_page":true,"end_cursor":"abc"},"edges":[]},"edge_location_to_top_posts":{"count":0,"edges":[]}}`
+
+func testSharedDataPage(location string) string {
+	return `<html><script type="text/javascript">window._sharedData = {"entry_data":{"LocationsPage":[{"graphql":{"location":` + location + `}}]}};</script></html>`
+}
+
+func testAdditionalDataPage(location string) string {
+	return `<html><script type="text/javascript">window.__additionalDataLoaded('/explore/locations/123/cafe/',{"graphql":{"location":` + location + `}});</script></html>`
+}
+
+// testRoundTripper sends every request to a test server and counts requests per path and query.
+type testRoundTripper struct {
+	target *url.URL
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func (self *testRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	self.mu.Lock()
+	self.requests[req.URL.RequestURI()]++
+	self.mu.Unlock()
+
+	req = req.Clone(req.Context())
+	req.URL.Scheme = self.target.Scheme
+	req.URL.Host = self.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (self *testRoundTripper) count(uri string) int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.requests[uri]
+}
+
+func newTestServerClient(t *testing.T, handler http.HandlerFunc) (*Client, *testRoundTripper) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &testRoundTripper{
+		target:   target,
+		requests: make(map[string]int),
+	}
+
+	return &Client{Client: &http.Client{Transport: transport}}, transport
+}
+
+func servePlace(page string, jsonBody string, pageStatus int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("__a") == "1" {
+			w.Write([]byte(jsonBody))
+			return
+		}
+
+		w.WriteHeader(pageStatus)
+		w.Write([]byte(page))
+	}
+}
+
+const testPlacePageURI = "/explore/locations/123/"
+const testPlaceJsonURI = "/explore/locations/123/?__a=1"
+
+func TestParsePlaceStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     string
+		json     string
+		stream   bool
+		expected PlaceParseStrategy
+		requests int
+	}{
+		{"shared data", testSharedDataPage(testLocationJson), "", false, PlaceStrategySharedData, 1},
+		{"shared data stream", testSharedDataPage(testLocationJson), "", true, PlaceStrategySharedData, 1},
+		{"additional data", testAdditionalDataPage(testLocationJson), "", false, PlaceStrategyAdditionalData, 1},
+		// a consumed stream cannot be parsed again, so the page is fetched twice
+		{"additional data after stream", testAdditionalDataPage(testLocationJson), "", true, PlaceStrategyAdditionalData, 2},
+		{"json", "<html></html>", `{"graphql":{"location":` + testLocationJson + `}}`, false, PlaceStrategyJson, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, transport := newTestServerClient(t, servePlace(test.page, test.json, http.StatusOK))
+
+			place, strategy, err := ParsePlaceWithOptions(client, "123", "", &ParsePlaceOptions{Stream: test.stream})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strategy != test.expected {
+				t.Fatalf("expected strategy %v, got %v", test.expected, strategy)
+			}
+
+			if place.Id != "123" || place.Address.CityName != "Moscow" || place.City == nil || place.City.Country != place.Country {
+				t.Fatalf("unexpected place %+v", place)
+			}
+
+			if n := transport.count(testPlacePageURI); n != test.requests {
+				t.Fatalf("expected %d page requests, got %d", test.requests, n)
+			}
+		})
+	}
+}
+
+func TestParsePlaceFailedFetchIsCached(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		client, transport := newTestServerClient(t, servePlace("rate limited", "{}", http.StatusTooManyRequests))
+
+		place, strategy, err := ParsePlaceWithOptions(client, "123", "", &ParsePlaceOptions{Stream: stream})
+		if err == nil || place != nil {
+			t.Fatalf("expected error, got %+v", place)
+		}
+
+		if strategy != PlaceStrategyNone {
+			t.Fatalf("expected no strategy on failure, got %v", strategy)
+		}
+
+		if n := transport.count(testPlacePageURI); n != 1 {
+			t.Fatalf("expected one page request with stream=%v, got %d", stream, n)
+		}
+
+		if n := transport.count(testPlaceJsonURI); n != 1 {
+			t.Fatalf("expected one json request with stream=%v, got %d", stream, n)
+		}
+	}
+}
+
+func TestParsePlaceNotFound(t *testing.T) {
+	client, transport := newTestServerClient(t, servePlace("", "", http.StatusNotFound))
+
+	_, strategy, err := ParsePlaceWithOptions(client, "123", "", nil)
+	if !merry.Is(err, ErrUndefinedLocation) {
+		t.Fatalf("expected ErrUndefinedLocation, got %v", err)
+	}
+
+	if strategy != PlaceStrategyNone {
+		t.Fatalf("expected no strategy, got %v", strategy)
+	}
+
+	if n := transport.count(testPlaceJsonURI); n != 0 {
+		t.Fatalf("expected no json request after not found, got %d", n)
+	}
+}
+
+func TestParsePlaceFromReaderNone(t *testing.T) {
+	_, strategy, err := ParsePlaceFromReader(strings.NewReader("<html></html>"), nil)
+	if err == nil || strategy != PlaceStrategyNone {
+		t.Fatalf("expected error with no strategy, got %v %v", strategy, err)
+	}
+}
+
+func TestPlaceContactsUseDirectoryCountry(t *testing.T) {
+	location := strings.Replace(testLocationJson, `"blurb":""`, `"blurb":"Call 8 (495) 123-45-67"`, 1)
+	location = strings.Replace(location, `\"country_code\":\"RU\"`, `\"country_code\":\"\"`, 1)
+
+	place, err := getPlaceInfoFromLocationJson([]byte(location))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if place.Contacts == nil || len(place.Contacts.Phones) != 1 || place.Contacts.Phones[0] != "+74951234567" {
+		t.Fatalf("got contacts %+v, want the phone parsed with the directory country", place.Contacts)
+	}
+
+	withoutCountry := strings.Replace(location, `"directory":{"country":{"id":"RU","name":"Russia","slug":"russia"},`, `"directory":{`, 1)
+	place, err = getPlaceInfoFromLocationJson([]byte(withoutCountry))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if place.Contacts != nil && len(place.Contacts.Phones) != 0 {
+		t.Fatalf("got phones %v without any country", place.Contacts.Phones)
+	}
+}
@@ -0,0 +1,138 @@
+package iglocparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ansel1/merry"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const IgTopSearchPath = "web/search/topsearch"
+
+type IgRequester interface {
+	SetHeaders(h http.Header, referrer string)
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type LocationCandidate struct {
+	*Location
+
+	Latitude         float64 `json:"lat,omitempty"`
+	Longitude        float64 `json:"lng,omitempty"`
+	Address          string  `json:"address,omitempty"`
+	CityName         string  `json:"city_name,omitempty"`
+	Title            string  `json:"title,omitempty"`
+	Subtitle         string  `json:"subtitle,omitempty"`
+	FacebookPlacesId string  `json:"facebook_places_id,omitempty"`
+	Distance         float64 `json:"distance,omitempty"`
+}
+
+func (self *LocationCandidate) String() string {
+	return fmt.Sprintf("[id=%v; name=%v; slug=%v; lat=%v; lng=%v]", self.Id, self.Name, self.Slug, self.Latitude, self.Longitude)
+}
+
+type igTopSearchLocation struct {
+	Pk               json.Number `json:"pk"`
+	Name             string      `json:"name"`
+	Address          string      `json:"address"`
+	City             string      `json:"city"`
+	Lat              float64     `json:"lat"`
+	Lng              float64     `json:"lng"`
+	FacebookPlacesId json.Number `json:"facebook_places_id"`
+}
+
+type igTopSearchResponse struct {
+	Places []struct {
+		Place struct {
+			Location *igTopSearchLocation `json:"location"`
+			Title    string               `json:"title"`
+			Subtitle string               `json:"subtitle"`
+			Slug     string               `json:"slug"`
+		} `json:"place"`
+		Position int `json:"position"`
+	} `json:"places"`
+	Status string `json:"status"`
+}
+
+func (self *igTopSearchResponse) candidates() []*LocationCandidate {
+	var candidates []*LocationCandidate
+	for _, item := range self.Places {
+		l := item.Place.Location
+		if l == nil {
+			continue
+		}
+
+		candidates = append(candidates, &LocationCandidate{
+			Location: &Location{
+				Id:   l.Pk.String(),
+				Name: l.Name,
+				Slug: item.Place.Slug,
+			},
+			Latitude:         l.Lat,
+			Longitude:        l.Lng,
+			Address:          l.Address,
+			CityName:         l.City,
+			Title:            item.Place.Title,
+			Subtitle:         item.Place.Subtitle,
+			FacebookPlacesId: l.FacebookPlacesId.String(),
+		})
+	}
+
+	return candidates
+}
+
+func SearchLocations(client IgRequester, query string) ([]*LocationCandidate, error) {
+	params := url.Values{}
+	params.Set("context", "place")
+	params.Set("query", query)
+
+	body, err := getIgJson(client, GetIgLinkWithLeadingSlash(IgTopSearchPath)+"?"+params.Encode(), GetIgLinkWithLeadingSlash(IgExploreLocationsPath))
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	res := &igTopSearchResponse{}
+	if err := json.Unmarshal(body, res); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	if res.Status != "ok" {
+		return nil, merry.WithUserMessage(ErrInvalidIgApiResponseCode, string(body))
+	}
+
+	return res.candidates(), nil
+}
+
+func (self *IgApiClient) SearchLocations(query string) ([]*LocationCandidate, error) {
+	return SearchLocations(self.client, query)
+}
+
+func getIgJson(client IgRequester, link string, referrer string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	client.SetHeaders(req.Header, referrer)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, merry.WithHTTPCode(ErrInvalidResponseStatus, res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,50 @@
+package iglocparser
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type StoreRecord struct {
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+type StoredCountry struct {
+	*Country
+	StoreRecord
+}
+
+type StoredCity struct {
+	*City
+	StoreRecord
+}
+
+type StoredLocation struct {
+	*Location
+	StoreRecord
+}
+
+type StoredPlace struct {
+	*Place
+	StoreRecord
+}
+
+// Store is implemented by the SQL backends in the store/sqlite and store/postgres packages.
+type Store interface {
+	UpsertCountries(countries []*Country) error
+	UpsertCities(cities []*City) error
+	UpsertLocations(locations []*Location) error
+	UpsertPlaces(places []*Place) error
+
+	GetCountries() ([]*StoredCountry, error)
+	GetCitiesByCountry(countryId string) ([]*StoredCity, error)
+	GetLocationsByCity(cityId string) ([]*StoredLocation, error)
+	GetPlace(id string) (*StoredPlace, error)
+	GetPlacesByCity(cityId string) ([]*StoredPlace, error)
+	GetPlacesInBoundingBox(box BoundingBox) ([]*StoredPlace, error)
+
+	Close() error
+}
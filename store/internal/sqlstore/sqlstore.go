@@ -0,0 +1,389 @@
+// Package sqlstore holds the SQL shared by the store backends.
+package sqlstore
+
+import (
+	"database/sql"
+	"github.com/ansel1/merry"
+	iglocparser "github.com/storiesg/go-iglocparser"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	// Numbered rewrites ? placeholders to $1, $2...
+	Numbered bool
+	// PlacesInBox replaces the default bounding box query, it gets min lat, min lng, max lat and max lng.
+	PlacesInBox string
+}
+
+type Store struct {
+	db          *sql.DB
+	numbered    bool
+	placesInBox string
+}
+
+func New(db *sql.DB, opts *Options) *Store {
+	store := &Store{
+		db: db,
+	}
+
+	if opts != nil {
+		store.numbered = opts.Numbered
+		store.placesInBox = opts.PlacesInBox
+	}
+
+	return store
+}
+
+func (self *Store) DB() *sql.DB {
+	return self.db
+}
+
+func (self *Store) rebind(query string) string {
+	if !self.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func Exec(db *sql.DB, queries []string) error {
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return merry.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+func (self *Store) upsert(query string, n int, args func(i int, now time.Time) []interface{}) error {
+	if n == 0 {
+		return nil
+	}
+
+	tx, err := self.db.Begin()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	stmt, err := tx.Prepare(self.rebind(query))
+	if err != nil {
+		tx.Rollback()
+		return merry.Wrap(err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for i := 0; i < n; i++ {
+		if _, err := stmt.Exec(args(i, now)...); err != nil {
+			tx.Rollback()
+			return merry.Wrap(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return merry.Wrap(err)
+	}
+
+	return nil
+}
+
+const CountryColumns = `id, name, slug, first_seen_at, last_seen_at`
+
+const CountryConflict = `ON CONFLICT (id) DO UPDATE SET name = excluded.name, slug = excluded.slug, last_seen_at = excluded.last_seen_at`
+
+const CityColumns = `id, name, slug, country_id, first_seen_at, last_seen_at`
+
+const CityConflict = `ON CONFLICT (id) DO UPDATE SET name = excluded.name, slug = excluded.slug,
+country_id = COALESCE(excluded.country_id, cities.country_id), last_seen_at = excluded.last_seen_at`
+
+const LocationColumns = `id, name, slug, city_id, country_id, first_seen_at, last_seen_at`
+
+const LocationConflict = `ON CONFLICT (id) DO UPDATE SET name = excluded.name, slug = excluded.slug,
+city_id = COALESCE(excluded.city_id, locations.city_id), country_id = COALESCE(excluded.country_id, locations.country_id),
+last_seen_at = excluded.last_seen_at`
+
+const PlaceColumns = `id, name, slug, lat, lng, blurb, website, phone, primary_alias_on_fb, profile_pic_url,
+has_public_page, category, media_count, street_address, zip_code, city_name, region_name, country_code,
+city_id, country_id, first_seen_at, last_seen_at`
+
+const PlaceConflict = `ON CONFLICT (id) DO UPDATE SET name = excluded.name, slug = excluded.slug, lat = excluded.lat, lng = excluded.lng,
+blurb = excluded.blurb, website = excluded.website, phone = excluded.phone,
+primary_alias_on_fb = excluded.primary_alias_on_fb, profile_pic_url = excluded.profile_pic_url,
+has_public_page = excluded.has_public_page, category = excluded.category, media_count = excluded.media_count,
+street_address = excluded.street_address, zip_code = excluded.zip_code, city_name = excluded.city_name,
+region_name = excluded.region_name, country_code = excluded.country_code,
+city_id = COALESCE(excluded.city_id, places.city_id), country_id = COALESCE(excluded.country_id, places.country_id),
+last_seen_at = excluded.last_seen_at`
+
+var sqlUpsertCountry = sqlUpsertQuery("countries", CountryColumns, CountryConflict)
+var sqlUpsertCity = sqlUpsertQuery("cities", CityColumns, CityConflict)
+var sqlUpsertLocation = sqlUpsertQuery("locations", LocationColumns, LocationConflict)
+var sqlUpsertPlace = sqlUpsertQuery("places", PlaceColumns, PlaceConflict)
+
+func ColumnList(columns string) []string {
+	var list []string
+	for _, column := range strings.Split(columns, ",") {
+		list = append(list, strings.TrimSpace(column))
+	}
+
+	return list
+}
+
+func sqlUpsertQuery(table string, columns string, conflict string) string {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ColumnList(columns))), ", ")
+	return "INSERT INTO " + table + " (" + columns + ") VALUES (" + placeholders + ")\n" + conflict
+}
+
+func CountryRow(c *iglocparser.Country, now time.Time) []interface{} {
+	return []interface{}{c.Id, c.Name, c.Slug, now, now}
+}
+
+func CityRow(c *iglocparser.City, now time.Time) []interface{} {
+	return []interface{}{c.Id, c.Name, c.Slug, nullableCountryId(c.Country), now, now}
+}
+
+func LocationRow(l *iglocparser.Location, now time.Time) []interface{} {
+	return []interface{}{l.Id, l.Name, l.Slug, nullableCityId(l.City), nullableCountryId(l.Country), now, now}
+}
+
+func (self *Store) UpsertCountries(countries []*iglocparser.Country) error {
+	return self.upsert(sqlUpsertCountry, len(countries), func(i int, now time.Time) []interface{} {
+		return CountryRow(countries[i], now)
+	})
+}
+
+func (self *Store) UpsertCities(cities []*iglocparser.City) error {
+	return self.upsert(sqlUpsertCity, len(cities), func(i int, now time.Time) []interface{} {
+		return CityRow(cities[i], now)
+	})
+}
+
+func (self *Store) UpsertLocations(locations []*iglocparser.Location) error {
+	return self.upsert(sqlUpsertLocation, len(locations), func(i int, now time.Time) []interface{} {
+		return LocationRow(locations[i], now)
+	})
+}
+
+func (self *Store) UpsertPlaces(places []*iglocparser.Place) error {
+	return self.upsert(sqlUpsertPlace, len(places), func(i int, now time.Time) []interface{} {
+		return PlaceRow(places[i], now)
+	})
+}
+
+func PlaceRow(p *iglocparser.Place, now time.Time) []interface{} {
+	return []interface{}{
+		p.Id, p.Name, p.Slug, p.Latitude, p.Longitude, p.Blurb, p.Website, p.Phone, p.PrimaryAliasOnFb, p.ProfilePicUrl,
+		p.HasPublicPage, p.Category, p.MediaCount, p.Address.StreetAddress, p.Address.ZipCode, p.Address.CityName,
+		p.Address.RegionName, p.Address.CountryCode, nullableCityId(p.City), nullableCountryId(p.Country), now, now,
+	}
+}
+
+func nullableCountryId(country *iglocparser.Country) interface{} {
+	if country == nil || country.Id == "" {
+		return nil
+	}
+
+	return country.Id
+}
+
+func nullableCityId(city *iglocparser.City) interface{} {
+	if city == nil || city.Id == "" {
+		return nil
+	}
+
+	return city.Id
+}
+
+func (self *Store) GetCountries() ([]*iglocparser.StoredCountry, error) {
+	rows, err := self.db.Query(`SELECT id, name, slug, first_seen_at, last_seen_at FROM countries ORDER BY id`)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	defer rows.Close()
+
+	var countries []*iglocparser.StoredCountry
+	for rows.Next() {
+		c := &iglocparser.StoredCountry{Country: &iglocparser.Country{}}
+		if err := rows.Scan(&c.Id, &c.Name, &c.Slug, &c.FirstSeenAt, &c.LastSeenAt); err != nil {
+			return nil, merry.Wrap(err)
+		}
+
+		countries = append(countries, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return countries, nil
+}
+
+func (self *Store) GetCitiesByCountry(countryId string) ([]*iglocparser.StoredCity, error) {
+	rows, err := self.db.Query(self.rebind(`SELECT ci.id, ci.name, ci.slug, ci.first_seen_at, ci.last_seen_at, COALESCE(co.id, ci.country_id), co.name, co.slug
+FROM cities ci LEFT JOIN countries co ON co.id = ci.country_id WHERE ci.country_id = ? ORDER BY ci.id`), countryId)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	defer rows.Close()
+
+	var cities []*iglocparser.StoredCity
+	for rows.Next() {
+		c := &iglocparser.StoredCity{City: &iglocparser.City{}}
+		var country entityRef
+		if err := rows.Scan(&c.Id, &c.Name, &c.Slug, &c.FirstSeenAt, &c.LastSeenAt, &country.id, &country.name, &country.slug); err != nil {
+			return nil, merry.Wrap(err)
+		}
+
+		c.Country = country.country()
+		cities = append(cities, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return cities, nil
+}
+
+func (self *Store) GetLocationsByCity(cityId string) ([]*iglocparser.StoredLocation, error) {
+	rows, err := self.db.Query(self.rebind(`SELECT l.id, l.name, l.slug, l.first_seen_at, l.last_seen_at,
+COALESCE(ci.id, l.city_id), ci.name, ci.slug, COALESCE(co.id, l.country_id), co.name, co.slug
+FROM locations l LEFT JOIN cities ci ON ci.id = l.city_id LEFT JOIN countries co ON co.id = l.country_id
+WHERE l.city_id = ? ORDER BY l.id`), cityId)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	defer rows.Close()
+
+	var locations []*iglocparser.StoredLocation
+	for rows.Next() {
+		l := &iglocparser.StoredLocation{Location: &iglocparser.Location{}}
+		var city, country entityRef
+		if err := rows.Scan(&l.Id, &l.Name, &l.Slug, &l.FirstSeenAt, &l.LastSeenAt,
+			&city.id, &city.name, &city.slug, &country.id, &country.name, &country.slug); err != nil {
+			return nil, merry.Wrap(err)
+		}
+
+		l.City = city.city()
+		l.Country = country.country()
+		if l.City != nil {
+			l.City.Country = l.Country
+		}
+
+		locations = append(locations, l)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return locations, nil
+}
+
+const SelectPlaces = `SELECT p.id, p.name, p.slug, p.lat, p.lng, p.blurb, p.website, p.phone, p.primary_alias_on_fb,
+p.profile_pic_url, p.has_public_page, p.category, p.media_count, p.street_address, p.zip_code, p.city_name,
+p.region_name, p.country_code, p.first_seen_at, p.last_seen_at,
+COALESCE(ci.id, p.city_id), ci.name, ci.slug, COALESCE(co.id, p.country_id), co.name, co.slug
+FROM places p LEFT JOIN cities ci ON ci.id = p.city_id LEFT JOIN countries co ON co.id = p.country_id`
+
+func (self *Store) GetPlace(id string) (*iglocparser.StoredPlace, error) {
+	places, err := self.queryPlaces(SelectPlaces+` WHERE p.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(places) == 0 {
+		return nil, merry.Wrap(iglocparser.ErrNotFound)
+	}
+
+	return places[0], nil
+}
+
+func (self *Store) GetPlacesByCity(cityId string) ([]*iglocparser.StoredPlace, error) {
+	return self.queryPlaces(SelectPlaces+` WHERE p.city_id = ? ORDER BY p.id`, cityId)
+}
+
+func (self *Store) GetPlacesInBoundingBox(box iglocparser.BoundingBox) ([]*iglocparser.StoredPlace, error) {
+	query := self.placesInBox
+	if query == "" {
+		query = SelectPlaces + ` WHERE p.lat >= ? AND p.lng >= ? AND p.lat <= ? AND p.lng <= ? ORDER BY p.id`
+	}
+
+	return self.queryPlaces(query, box.MinLat, box.MinLng, box.MaxLat, box.MaxLng)
+}
+
+func (self *Store) queryPlaces(query string, args ...interface{}) ([]*iglocparser.StoredPlace, error) {
+	rows, err := self.db.Query(self.rebind(query), args...)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	defer rows.Close()
+
+	var places []*iglocparser.StoredPlace
+	for rows.Next() {
+		p := &iglocparser.StoredPlace{Place: &iglocparser.Place{}}
+		var city, country entityRef
+		if err := rows.Scan(&p.Id, &p.Name, &p.Slug, &p.Latitude, &p.Longitude, &p.Blurb, &p.Website, &p.Phone,
+			&p.PrimaryAliasOnFb, &p.ProfilePicUrl, &p.HasPublicPage, &p.Category, &p.MediaCount,
+			&p.Address.StreetAddress, &p.Address.ZipCode, &p.Address.CityName, &p.Address.RegionName, &p.Address.CountryCode,
+			&p.FirstSeenAt, &p.LastSeenAt, &city.id, &city.name, &city.slug, &country.id, &country.name, &country.slug); err != nil {
+			return nil, merry.Wrap(err)
+		}
+
+		p.City = city.city()
+		p.Country = country.country()
+		if p.City != nil {
+			p.City.Country = p.Country
+		}
+
+		places = append(places, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	return places, nil
+}
+
+func (self *Store) Close() error {
+	return self.db.Close()
+}
+
+// entityRef is a joined parent, parents that were never stored keep only the id of the child's column.
+type entityRef struct {
+	id   sql.NullString
+	name sql.NullString
+	slug sql.NullString
+}
+
+func (self *entityRef) country() *iglocparser.Country {
+	if !self.id.Valid {
+		return nil
+	}
+
+	return &iglocparser.Country{Id: self.id.String, Name: self.name.String, Slug: self.slug.String}
+}
+
+func (self *entityRef) city() *iglocparser.City {
+	if !self.id.Valid {
+		return nil
+	}
+
+	return &iglocparser.City{Id: self.id.String, Name: self.name.String, Slug: self.slug.String}
+}
@@ -0,0 +1,242 @@
+// Package postgres is the PostgreSQL store backend, it needs the PostGIS extension.
+package postgres
+
+import (
+	"database/sql"
+	"github.com/ansel1/merry"
+	"github.com/lib/pq"
+	iglocparser "github.com/storiesg/go-iglocparser"
+	"github.com/storiesg/go-iglocparser/store/internal/sqlstore"
+	"time"
+)
+
+var migrations = []string{
+	`CREATE EXTENSION IF NOT EXISTS postgis`,
+	`CREATE TABLE IF NOT EXISTS countries (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	slug TEXT NOT NULL DEFAULT '',
+	first_seen_at TIMESTAMPTZ NOT NULL,
+	last_seen_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS cities (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	slug TEXT NOT NULL DEFAULT '',
+	country_id TEXT,
+	first_seen_at TIMESTAMPTZ NOT NULL,
+	last_seen_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS cities_country_id_idx ON cities (country_id);
+CREATE TABLE IF NOT EXISTS locations (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	slug TEXT NOT NULL DEFAULT '',
+	city_id TEXT,
+	country_id TEXT,
+	first_seen_at TIMESTAMPTZ NOT NULL,
+	last_seen_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS locations_city_id_idx ON locations (city_id);
+CREATE TABLE IF NOT EXISTS places (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	slug TEXT NOT NULL DEFAULT '',
+	lat DOUBLE PRECISION NOT NULL DEFAULT 0,
+	lng DOUBLE PRECISION NOT NULL DEFAULT 0,
+	blurb TEXT NOT NULL DEFAULT '',
+	website TEXT NOT NULL DEFAULT '',
+	phone TEXT NOT NULL DEFAULT '',
+	primary_alias_on_fb TEXT NOT NULL DEFAULT '',
+	profile_pic_url TEXT NOT NULL DEFAULT '',
+	has_public_page BOOLEAN NOT NULL DEFAULT FALSE,
+	category TEXT NOT NULL DEFAULT '',
+	media_count INTEGER NOT NULL DEFAULT 0,
+	street_address TEXT NOT NULL DEFAULT '',
+	zip_code TEXT NOT NULL DEFAULT '',
+	city_name TEXT NOT NULL DEFAULT '',
+	region_name TEXT NOT NULL DEFAULT '',
+	country_code TEXT NOT NULL DEFAULT '',
+	city_id TEXT,
+	country_id TEXT,
+	first_seen_at TIMESTAMPTZ NOT NULL,
+	last_seen_at TIMESTAMPTZ NOT NULL,
+	geog GEOGRAPHY(Point, 4326) GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(lng, lat), 4326)::geography) STORED
+);
+CREATE INDEX IF NOT EXISTS places_city_id_idx ON places (city_id);
+CREATE INDEX IF NOT EXISTS places_geog_idx ON places USING GIST (geog)`,
+}
+
+const sqlPlacesInBox = sqlstore.SelectPlaces + `
+WHERE p.geog && ST_MakeEnvelope($2, $1, $4, $3, 4326)::geography
+AND p.lat BETWEEN $1 AND $3 AND p.lng BETWEEN $2 AND $4
+ORDER BY p.id`
+
+var _ iglocparser.Store = (*Store)(nil)
+
+type Store struct {
+	*sqlstore.Store
+}
+
+func New(db *sql.DB) (*Store, error) {
+	store := &Store{
+		Store: sqlstore.New(db, &sqlstore.Options{
+			Numbered:    true,
+			PlacesInBox: sqlPlacesInBox,
+		}),
+	}
+
+	if err := store.Migrate(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func Open(dsn string) (*Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	store, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (self *Store) Migrate() error {
+	_, err := self.DB().Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	for i, migration := range migrations {
+		version := i + 1
+
+		tx, err := self.DB().Begin()
+		if err != nil {
+			return merry.Wrap(err)
+		}
+
+		if _, err := tx.Exec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE`); err != nil {
+			tx.Rollback()
+			return merry.Wrap(err)
+		}
+
+		var applied bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied); err != nil {
+			tx.Rollback()
+			return merry.Wrap(err)
+		}
+
+		if applied {
+			tx.Rollback()
+			continue
+		}
+
+		if _, err := tx.Exec(migration); err != nil {
+			tx.Rollback()
+			return merry.Wrap(err)
+		}
+
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			tx.Rollback()
+			return merry.Wrap(err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return merry.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+func (self *Store) copyUpsert(table string, columns string, conflict string, n int, row func(i int, now time.Time) []interface{}) error {
+	if n == 0 {
+		return nil
+	}
+
+	tx, err := self.DB().Begin()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	staging := table + "_import"
+	_, err = tx.Exec(`CREATE TEMP TABLE ` + staging + ` (LIKE ` + table + ` INCLUDING DEFAULTS) ON COMMIT DROP`)
+	if err != nil {
+		tx.Rollback()
+		return merry.Wrap(err)
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(staging, sqlstore.ColumnList(columns)...))
+	if err != nil {
+		tx.Rollback()
+		return merry.Wrap(err)
+	}
+
+	now := time.Now().UTC()
+	for i := 0; i < n; i++ {
+		if _, err := stmt.Exec(row(i, now)...); err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return merry.Wrap(err)
+		}
+	}
+
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		tx.Rollback()
+		return merry.Wrap(err)
+	}
+
+	if err := stmt.Close(); err != nil {
+		tx.Rollback()
+		return merry.Wrap(err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO ` + table + ` (` + columns + `)
+SELECT DISTINCT ON (id) ` + columns + ` FROM ` + staging + ` ORDER BY id
+` + conflict)
+	if err != nil {
+		tx.Rollback()
+		return merry.Wrap(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return merry.Wrap(err)
+	}
+
+	return nil
+}
+
+func (self *Store) UpsertCountries(countries []*iglocparser.Country) error {
+	return self.copyUpsert("countries", sqlstore.CountryColumns, sqlstore.CountryConflict, len(countries), func(i int, now time.Time) []interface{} {
+		return sqlstore.CountryRow(countries[i], now)
+	})
+}
+
+func (self *Store) UpsertCities(cities []*iglocparser.City) error {
+	return self.copyUpsert("cities", sqlstore.CityColumns, sqlstore.CityConflict, len(cities), func(i int, now time.Time) []interface{} {
+		return sqlstore.CityRow(cities[i], now)
+	})
+}
+
+func (self *Store) UpsertLocations(locations []*iglocparser.Location) error {
+	return self.copyUpsert("locations", sqlstore.LocationColumns, sqlstore.LocationConflict, len(locations), func(i int, now time.Time) []interface{} {
+		return sqlstore.LocationRow(locations[i], now)
+	})
+}
+
+func (self *Store) UpsertPlaces(places []*iglocparser.Place) error {
+	return self.copyUpsert("places", sqlstore.PlaceColumns, sqlstore.PlaceConflict, len(places), func(i int, now time.Time) []interface{} {
+		return sqlstore.PlaceRow(places[i], now)
+	})
+}
@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	iglocparser "github.com/storiesg/go-iglocparser"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestStore opens IGLOC_TEST_POSTGRES_DSN and works in a throwaway schema, the database
+// needs the PostGIS extension available.
+func newTestStore(t *testing.T) (*Store, *sql.DB) {
+	t.Helper()
+
+	dsn := os.Getenv("IGLOC_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("IGLOC_TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	schema := fmt.Sprintf("igloc_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		db.Close()
+	})
+
+	if _, err := db.Exec(`SET search_path TO ` + schema + `, public`); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, db
+}
+
+func TestStoreMigrateIdempotent(t *testing.T) {
+	store, db := newTestStore(t)
+
+	if err := store.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT count(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != len(migrations) {
+		t.Fatalf("got %d applied migrations, want %d", count, len(migrations))
+	}
+}
+
+func TestStoreCopyUpsert(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	country := &iglocparser.Country{Id: "RU", Name: "Russia", Slug: "russia"}
+	city := &iglocparser.City{Id: "c102", Name: "Moscow", Slug: "moscow", Country: country}
+	if err := store.UpsertCountries([]*iglocparser.Country{country}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpsertCities([]*iglocparser.City{city}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Duplicate ids in one batch must not break the COPY staging insert.
+	place := &iglocparser.Place{Id: "1", Name: "Cafe", Latitude: 55.75, Longitude: 37.61, City: city, Country: country}
+	if err := store.UpsertPlaces([]*iglocparser.Place{place, place}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.GetPlace("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	renamed := &iglocparser.Place{Id: "1", Name: "Cafe 2", Latitude: 55.75, Longitude: 37.61}
+	if err := store.UpsertPlaces([]*iglocparser.Place{renamed}); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := store.GetPlace("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.Name != "Cafe 2" {
+		t.Errorf("got name %q, want the updated one", second.Name)
+	}
+	if !second.FirstSeenAt.Equal(first.FirstSeenAt) {
+		t.Errorf("first_seen_at changed from %v to %v", first.FirstSeenAt, second.FirstSeenAt)
+	}
+	if !second.LastSeenAt.After(first.LastSeenAt) {
+		t.Errorf("last_seen_at %v is not after %v", second.LastSeenAt, first.LastSeenAt)
+	}
+	if second.City == nil || second.City.Id != "c102" {
+		t.Errorf("got city %+v, want the stored one kept", second.City)
+	}
+}
+
+func TestStorePlacesInBoundingBox(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	places := []*iglocparser.Place{
+		{Id: "1", Name: "Inside", Latitude: 55.75, Longitude: 37.61},
+		{Id: "2", Name: "Outside", Latitude: 10, Longitude: 10},
+		{Id: "3", Name: "Edge", Latitude: 56, Longitude: 38},
+	}
+	if err := store.UpsertPlaces(places); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := store.GetPlacesInBoundingBox(iglocparser.BoundingBox{MinLat: 55, MinLng: 37, MaxLat: 56, MaxLng: 38})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 2 || found[0].Id != "1" || found[1].Id != "3" {
+		t.Fatalf("got %d places, want places 1 and 3", len(found))
+	}
+}
@@ -0,0 +1,98 @@
+// Package sqlite is the SQLite store backend, it uses the pure Go modernc.org/sqlite driver.
+package sqlite
+
+import (
+	"database/sql"
+	"github.com/ansel1/merry"
+	iglocparser "github.com/storiesg/go-iglocparser"
+	"github.com/storiesg/go-iglocparser/store/internal/sqlstore"
+	_ "modernc.org/sqlite"
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS countries (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	slug TEXT NOT NULL DEFAULT '',
+	first_seen_at TIMESTAMP NOT NULL,
+	last_seen_at TIMESTAMP NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS cities (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	slug TEXT NOT NULL DEFAULT '',
+	country_id TEXT,
+	first_seen_at TIMESTAMP NOT NULL,
+	last_seen_at TIMESTAMP NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS cities_country_id_idx ON cities (country_id)`,
+	`CREATE TABLE IF NOT EXISTS locations (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	slug TEXT NOT NULL DEFAULT '',
+	city_id TEXT,
+	country_id TEXT,
+	first_seen_at TIMESTAMP NOT NULL,
+	last_seen_at TIMESTAMP NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS locations_city_id_idx ON locations (city_id)`,
+	`CREATE TABLE IF NOT EXISTS places (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	slug TEXT NOT NULL DEFAULT '',
+	lat REAL NOT NULL DEFAULT 0,
+	lng REAL NOT NULL DEFAULT 0,
+	blurb TEXT NOT NULL DEFAULT '',
+	website TEXT NOT NULL DEFAULT '',
+	phone TEXT NOT NULL DEFAULT '',
+	primary_alias_on_fb TEXT NOT NULL DEFAULT '',
+	profile_pic_url TEXT NOT NULL DEFAULT '',
+	has_public_page BOOLEAN NOT NULL DEFAULT 0,
+	category TEXT NOT NULL DEFAULT '',
+	media_count INTEGER NOT NULL DEFAULT 0,
+	street_address TEXT NOT NULL DEFAULT '',
+	zip_code TEXT NOT NULL DEFAULT '',
+	city_name TEXT NOT NULL DEFAULT '',
+	region_name TEXT NOT NULL DEFAULT '',
+	country_code TEXT NOT NULL DEFAULT '',
+	city_id TEXT,
+	country_id TEXT,
+	first_seen_at TIMESTAMP NOT NULL,
+	last_seen_at TIMESTAMP NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS places_city_id_idx ON places (city_id)`,
+	`CREATE INDEX IF NOT EXISTS places_lat_lng_idx ON places (lat, lng)`,
+}
+
+var _ iglocparser.Store = (*Store)(nil)
+
+type Store struct {
+	*sqlstore.Store
+}
+
+func New(db *sql.DB) (*Store, error) {
+	if err := sqlstore.Exec(db, schema); err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		Store: sqlstore.New(db, nil),
+	}, nil
+}
+
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	store, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"github.com/ansel1/merry"
+	iglocparser "github.com/storiesg/go-iglocparser"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	store, err := Open(filepath.Join(t.TempDir(), "igloc.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	store := newTestStore(t)
+
+	country := &iglocparser.Country{Id: "RU", Name: "Russia", Slug: "russia"}
+	city := &iglocparser.City{Id: "c102", Name: "Moscow", Slug: "moscow", Country: country}
+	place := &iglocparser.Place{Id: "1", Name: "Cafe", Latitude: 55.75, Longitude: 37.61, City: city, Country: country}
+	far := &iglocparser.Place{Id: "2", Name: "Far", Latitude: 10, Longitude: 10}
+
+	if err := store.UpsertCountries([]*iglocparser.Country{country}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpsertCities([]*iglocparser.City{city}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpsertPlaces([]*iglocparser.Place{place, far}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.GetPlace("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	// A later sighting without a city must keep the stored one and the first sighting time.
+	renamed := &iglocparser.Place{Id: "1", Name: "Cafe 2", Latitude: 55.75, Longitude: 37.61}
+	if err := store.UpsertPlaces([]*iglocparser.Place{renamed}); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := store.GetPlace("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.Name != "Cafe 2" {
+		t.Errorf("got name %q, want the updated one", second.Name)
+	}
+	if !second.FirstSeenAt.Equal(first.FirstSeenAt) {
+		t.Errorf("first_seen_at changed from %v to %v", first.FirstSeenAt, second.FirstSeenAt)
+	}
+	if !second.LastSeenAt.After(first.LastSeenAt) {
+		t.Errorf("last_seen_at %v is not after %v", second.LastSeenAt, first.LastSeenAt)
+	}
+	if second.City == nil || second.City.Id != "c102" || second.City.Country == nil || second.City.Country.Id != "RU" {
+		t.Errorf("got city %+v, want the stored one kept", second.City)
+	}
+
+	places, err := store.GetPlacesInBoundingBox(iglocparser.BoundingBox{MinLat: 55, MinLng: 37, MaxLat: 56, MaxLng: 38})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 1 || places[0].Id != "1" {
+		t.Errorf("got %d places in the box, want only place 1", len(places))
+	}
+
+	places, err = store.GetPlacesByCity("c102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 1 || places[0].Id != "1" {
+		t.Errorf("got %d places in the city, want only place 1", len(places))
+	}
+
+	if _, err := store.GetPlace("3"); !merry.Is(err, iglocparser.ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "igloc.db")
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location := &iglocparser.Location{Id: "1", Name: "Cafe", City: &iglocparser.City{Id: "c102"}}
+	if err := store.UpsertLocations([]*iglocparser.Location{location}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	locations, err := store.GetLocationsByCity("c102")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(locations) != 1 || locations[0].Name != "Cafe" {
+		t.Fatalf("got %d locations after reopening, want 1", len(locations))
+	}
+}
+
+func TestStoreParentsNotStored(t *testing.T) {
+	store := newTestStore(t)
+
+	country := &iglocparser.Country{Id: "RU"}
+	city := &iglocparser.City{Id: "c102", Country: country}
+	if err := store.UpsertCities([]*iglocparser.City{city}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpsertLocations([]*iglocparser.Location{{Id: "1", City: &iglocparser.City{Id: "c103"}, Country: country}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpsertPlaces([]*iglocparser.Place{{Id: "1", City: &iglocparser.City{Id: "c103"}, Country: country}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cities, err := store.GetCitiesByCountry("RU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cities) != 1 || cities[0].Country == nil || cities[0].Country.Id != "RU" {
+		t.Fatalf("expected the country id without a stored country, got %+v", cities)
+	}
+
+	locations, err := store.GetLocationsByCity("c103")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 1 || locations[0].City == nil || locations[0].City.Id != "c103" || locations[0].Country == nil || locations[0].Country.Id != "RU" {
+		t.Fatalf("expected city and country ids without stored parents, got %+v", locations)
+	}
+
+	places, err := store.GetPlacesByCity("c103")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 1 || places[0].City == nil || places[0].City.Id != "c103" || places[0].Country == nil || places[0].Country.Id != "RU" {
+		t.Fatalf("expected city and country ids without stored parents, got %+v", places)
+	}
+}